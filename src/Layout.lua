@@ -25,6 +25,9 @@ local DeepCopy         = require("DeepCopy")
 ---@alias TextStruct {pos1:string, style:string, font:string, text:string, mouse:MouseStruct, type:string, layer:integer, visible:StringOrBool, hitable:StringOrBool, replicate:ReplicateStruct}
 ---@alias LineStruct {pos1:string, pos2:string, style:string, mouse:MouseStruct, mouse:MouseStruct, type:string, layer:integer, visible:StringOrBool, hitable:StringOrBool, replicate:ReplicateStruct}
 ---@alias CircleStruct {pos1:string, radius:number, style:string, mouse:MouseStruct, mouse:MouseStruct, type:string, layer:integer, visible:StringOrBool, hitable:StringOrBool, replicate:ReplicateStruct}
+---@alias TriangleStruct {pos1:string, pos2:string, pos3:string, style:string, mouse:MouseStruct, type:string, layer:integer, visible:StringOrBool, hitable:StringOrBool, replicate:ReplicateStruct}
+---@alias BezierStruct {pos1:string, pos2:string, pos3:string, style:string, mouse:MouseStruct, type:string, layer:integer, visible:StringOrBool, hitable:StringOrBool, replicate:ReplicateStruct}
+---@alias QuadStruct {pos1:string, pos2:string, pos3:string, pos4:string, style:string, mouse:MouseStruct, type:string, layer:integer, visible:StringOrBool, hitable:StringOrBool, replicate:ReplicateStruct}
 ---@alias ImageStruct {pos1:string, dimensions:string, sub:string, subDimensions:string, url:string, mouse:MouseStruct, type:string, layer:integer, visible:StringOrBool, hitable:StringOrBool, replicate:ReplicateStruct}
 
 ---@alias Page Layer[]
@@ -286,6 +289,55 @@ function Layout.New(screen, behaviour, binder, stream)
         return circle
     end
 
+    ---@param layer Layer
+    ---@param data TriangleStruct
+    ---@return Triangle|nil
+    local function createTriangle(layer, data)
+        local triangle = layer.Triangle(Vec2.New(), Vec2.New(), Vec2.New())
+
+        if not (bindPos(data.pos1, triangle, "Pos1", "triangle")
+                and bindPos(data.pos2, triangle, "Pos2", "triangle")
+                and bindPos(data.pos3, triangle, "Pos3", "triangle")) then
+            return nil
+        end
+
+        applyBindings(triangle, data)
+        return triangle
+    end
+
+    ---@param layer Layer
+    ---@param data BezierStruct
+    ---@return Bezier|nil
+    local function createBezier(layer, data)
+        local bezier = layer.Bezier(Vec2.New(), Vec2.New(), Vec2.New())
+
+        if not (bindPos(data.pos1, bezier, "Pos1", "bezier")
+                and bindPos(data.pos2, bezier, "Pos2", "bezier")
+                and bindPos(data.pos3, bezier, "Pos3", "bezier")) then
+            return nil
+        end
+
+        applyBindings(bezier, data)
+        return bezier
+    end
+
+    ---@param layer Layer
+    ---@param data QuadStruct
+    ---@return Quad|nil
+    local function createQuad(layer, data)
+        local quad = layer.Quad(Vec2.New(), Vec2.New(), Vec2.New(), Vec2.New())
+
+        if not (bindPos(data.pos1, quad, "Pos1", "quad")
+                and bindPos(data.pos2, quad, "Pos2", "quad")
+                and bindPos(data.pos3, quad, "Pos3", "quad")
+                and bindPos(data.pos4, quad, "PosD", "quad")) then
+            return nil
+        end
+
+        applyBindings(quad, data)
+        return quad
+    end
+
     ---@param layer Layer
     ---@param data ImageStruct
     ---@return Image|nil
@@ -312,7 +364,7 @@ function Layout.New(screen, behaviour, binder, stream)
     ---@param comp BaseCompStruct
     ---@return boolean
     local function createComponent(comp)
-        local res = nil ---@type Box|Circle|Line|Image|Text|nil
+        local res = nil ---@type Box|Circle|Line|Image|Text|Triangle|Quad|Bezier|nil
         local layer = comp.layer
         local t = tostring(comp.type)
 
@@ -333,6 +385,15 @@ function Layout.New(screen, behaviour, binder, stream)
             elseif t == "image" then
                 ---@cast comp ImageStruct
                 res = createImage(l, comp)
+            elseif t == "triangle" then
+                ---@cast comp TriangleStruct
+                res = createTriangle(l, comp)
+            elseif t == "quad" then
+                ---@cast comp QuadStruct
+                res = createQuad(l, comp)
+            elseif t == "bezier" then
+                ---@cast comp BezierStruct
+                res = createBezier(l, comp)
             end
         else
             rs.Log("Invalid layer number '" .. tostring(layer) .. "', type " .. t)
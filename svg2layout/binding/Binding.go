@@ -0,0 +1,296 @@
+// Package binding parses and validates the data binding expressions of the layout, such as
+// $vec2(path{gauge:value}:init{(1,2)}:percent{(10,20)}:interval{0.1}:op{mul})
+package binding
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Kind string
+
+const (
+	Number  Kind = "num"
+	String  Kind = "str"
+	Vec2    Kind = "vec2"
+	Boolean Kind = "bool"
+)
+
+// ReplicationMarker is replaced by the replication count of the component before the binding is created
+const ReplicationMarker = "[#]"
+
+// Binding is a single binding expression
+type Binding struct {
+	Kind Kind
+	// Path is the path to the object holding the value in the incoming data, empty for the root object.
+	Path string
+	// Key is the name of the value in the object
+	Key      string
+	Init     string
+	Percent  *string
+	Interval *float64
+	// Op is the operator applied to the initial and incoming values, empty, "mul" or "div"
+	Op     string
+	Format *string
+}
+
+// Expression is one or more bindings, only strings may be concatenated by separating them with |
+type Expression []Binding
+
+var kindExp = regexp.MustCompile(`^\$([a-zA-Z0-9]+)\((.*)\)$`)
+var attributeExp = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+// Paths and keys are matched like the Lua pattern of the Binder, [^%s:{}]
+var pathExp = regexp.MustCompile(`^[^\s:{}]*$`)
+var keyExp = regexp.MustCompile(`^[^\s:{}]+$`)
+var intervalExp = regexp.MustCompile(`^[0-9]*\.?[0-9]+$`)
+var vec2Exp = regexp.MustCompile(`^\(\s*(-?[0-9.]+(?:[eE][-+]?[0-9]+)?)\s*,\s*(-?[0-9.]+(?:[eE][-+]?[0-9]+)?)\s*\)$`)
+var verbExp = regexp.MustCompile(`%[-+ #0]*[0-9]*(?:\.[0-9]+)?([a-zA-Z%])`)
+
+// Parse parses and validates the expression
+func Parse(expression string) (expr Expression, err error) {
+	parts := splitConcatenation(expression)
+
+	for _, part := range parts {
+		var b Binding
+		if b, err = parseBinding(strings.TrimSpace(part)); err != nil {
+			return nil, err
+		}
+
+		if len(parts) > 1 && b.Kind != String {
+			return nil, fmt.Errorf("only $str bindings can be concatenated with |, not $%s", b.Kind)
+		}
+
+		expr = append(expr, b)
+	}
+
+	return
+}
+
+// splitConcatenation splits the expression at the | that are not within the value of an attribute
+func splitConcatenation(expression string) (parts []string) {
+	depth := 0
+	start := 0
+	for i, r := range expression {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+			}
+		case '|':
+			if depth == 0 {
+				parts = append(parts, expression[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(parts, expression[start:])
+}
+
+func parseBinding(text string) (b Binding, err error) {
+	m := kindExp.FindStringSubmatch(text)
+	if m == nil {
+		return b, fmt.Errorf("'%s' is not a binding, expected $type(...)", text)
+	}
+
+	b.Kind = Kind(m[1])
+	switch b.Kind {
+	case Number, String, Vec2, Boolean:
+	case "boolean":
+		return b, fmt.Errorf("unknown binding type '$boolean', use '$bool'")
+	default:
+		return b, fmt.Errorf("unknown binding type '$%s'", m[1])
+	}
+
+	var attributes map[string]string
+	if attributes, err = parseAttributes(m[2]); err != nil {
+		return
+	}
+
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err = b.setAttribute(name, attributes[name]); err != nil {
+			return
+		}
+	}
+
+	if _, found := attributes["path"]; !found {
+		return b, fmt.Errorf("'path' is missing in $%s", b.Kind)
+	}
+
+	if _, found := attributes["init"]; !found {
+		return b, fmt.Errorf("'init' is missing in $%s", b.Kind)
+	}
+
+	err = b.validate()
+	return
+}
+
+// parseAttributes splits the content of a binding, such as path{a/b:c}:init{1}, into its attributes.
+// As in the screen, a value ends at the first closing brace.
+func parseAttributes(content string) (attributes map[string]string, err error) {
+	attributes = map[string]string{}
+
+	rest := content
+	for rest != "" {
+		open := strings.Index(rest, "{")
+		if open < 0 {
+			return nil, fmt.Errorf("expected name{value} at '%s'", rest)
+		}
+
+		name := rest[:open]
+		if !attributeExp.MatchString(name) {
+			return nil, fmt.Errorf("invalid attribute name '%s'", name)
+		}
+
+		end := strings.Index(rest[open:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("missing '}' after %s{", name)
+		}
+		end += open
+
+		if _, found := attributes[name]; found {
+			return nil, fmt.Errorf("'%s' is given more than once", name)
+		}
+		attributes[name] = rest[open+1 : end]
+
+		rest = rest[end+1:]
+		if rest != "" {
+			if rest[0] != ':' {
+				return nil, fmt.Errorf("expected ':' between attributes at '%s'", rest)
+			}
+			rest = rest[1:]
+		}
+	}
+
+	return
+}
+
+func (b *Binding) setAttribute(name, value string) (err error) {
+	switch name {
+	case "path":
+		path, key, found := strings.Cut(value, ":")
+		if !found || strings.Contains(key, ":") {
+			return fmt.Errorf("path '%s' must be on the form path/to:key", value)
+		}
+		b.Path, b.Key = path, key
+	case "init":
+		b.Init = value
+	case "percent":
+		b.Percent = &value
+	case "interval":
+		var interval float64
+		if !intervalExp.MatchString(value) {
+			return fmt.Errorf("interval '%s' is not a positive number", value)
+		}
+		if interval, err = strconv.ParseFloat(value, 64); err != nil {
+			return
+		}
+		b.Interval = &interval
+	case "op":
+		if value != "mul" && value != "div" {
+			return fmt.Errorf("unknown operator '%s', expected mul or div", value)
+		}
+		b.Op = value
+	case "format":
+		b.Format = &value
+	default:
+		return fmt.Errorf("unknown attribute '%s' in $%s", name, b.Kind)
+	}
+
+	return
+}
+
+// validate checks the values of the binding against its kind, as replicated components would have them.
+func (b *Binding) validate() error {
+	path := replicated(b.Path)
+	if !pathExp.MatchString(path) {
+		return fmt.Errorf("path '%s' may not contain whitespace, ':', '{' or '}'", b.Path)
+	}
+
+	if !keyExp.MatchString(replicated(b.Key)) {
+		return fmt.Errorf("key '%s' must be non-empty and may not contain whitespace, ':', '{' or '}'", b.Key)
+	}
+
+	init := replicated(b.Init)
+
+	switch b.Kind {
+	case Number:
+		if _, err := strconv.ParseFloat(strings.TrimSpace(init), 64); err != nil {
+			return fmt.Errorf("init value '%s' of $num is not a number", b.Init)
+		}
+		if b.Percent != nil {
+			if _, err := strconv.ParseFloat(strings.TrimSpace(replicated(*b.Percent)), 64); err != nil {
+				return fmt.Errorf("percent value '%s' of $num is not a number", *b.Percent)
+			}
+		}
+		if b.Format != nil {
+			return checkFormat(*b.Format, "diouxXeEfgGaAcs", b.Kind)
+		}
+	case Vec2:
+		if !vec2Exp.MatchString(init) {
+			return fmt.Errorf("init value '%s' of $vec2 is not a vector, expected (x,y)", b.Init)
+		}
+		if b.Percent != nil && !vec2Exp.MatchString(replicated(*b.Percent)) {
+			return fmt.Errorf("percent value '%s' of $vec2 is not a vector, expected (x,y)", *b.Percent)
+		}
+		if b.Format != nil {
+			return fmt.Errorf("format is not supported by $vec2")
+		}
+	case String, Boolean:
+		if b.Percent != nil {
+			return fmt.Errorf("percent is only supported by $num and $vec2, not $%s", b.Kind)
+		}
+		if b.Op != "" {
+			return fmt.Errorf("op is only supported by $num and $vec2, not $%s", b.Kind)
+		}
+		if b.Kind == Boolean {
+			if v := strings.TrimSpace(init); v != "true" && v != "false" && v != "1" && v != "0" {
+				return fmt.Errorf("init value '%s' of $bool is not a boolean, expected true or false", b.Init)
+			}
+			if b.Format != nil {
+				return fmt.Errorf("format is not supported by $bool")
+			}
+		} else if b.Format != nil {
+			return checkFormat(*b.Format, "sq", b.Kind)
+		}
+	}
+
+	return nil
+}
+
+// checkFormat checks that the Lua format string has a single conversion, of one of the allowed verbs.
+func checkFormat(format, verbs string, kind Kind) error {
+	count := 0
+	for _, m := range verbExp.FindAllStringSubmatch(format, -1) {
+		if m[1] == "%" {
+			continue
+		}
+		if !strings.Contains(verbs, m[1]) {
+			return fmt.Errorf("format '%s' of $%s has unsupported conversion '%s'", format, kind, m[0])
+		}
+		count++
+	}
+
+	if count != 1 {
+		return fmt.Errorf("format '%s' of $%s must have exactly one conversion", format, kind)
+	}
+
+	return nil
+}
+
+// replicated returns the value as it would be for a replicated component
+func replicated(value string) string {
+	return strings.ReplaceAll(value, ReplicationMarker, "1")
+}
@@ -0,0 +1,89 @@
+package binding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	expr, err := Parse("$vec2(path{path/to:value}:init{(1,2)}:percent{(10, 20)}:interval{0.1}:op{mul})")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(expr))
+	b := expr[0]
+	assert.Equal(t, Vec2, b.Kind)
+	assert.Equal(t, "path/to", b.Path)
+	assert.Equal(t, "value", b.Key)
+	assert.Equal(t, "(1,2)", b.Init)
+	assert.Equal(t, "(10, 20)", *b.Percent)
+	assert.Equal(t, 0.1, *b.Interval)
+	assert.Equal(t, "mul", b.Op)
+	assert.Nil(t, b.Format)
+
+	expr, err = Parse("$str(path{a:b}:init{-}:format{Fuel: %s | }:interval{0})|$str(path{:c}:init{x})")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(expr))
+	assert.Equal(t, "Fuel: %s | ", *expr[0].Format)
+	assert.Equal(t, "", expr[1].Path)
+	assert.Equal(t, "c", expr[1].Key)
+}
+
+func TestParseValid(t *testing.T) {
+	valid := []string{
+		"$num(path{a/b:c}:init{12.34})",
+		"$num(path{a:c}:init{-1}:percent{100}:op{div}:format{%0.2f %%})",
+		"$num(path{a:c}:init{1}:format{%d})",
+		"$str(path{path/to/data:key}:format{My command: '%s'}:interval{0.5}:init{init value})",
+		"$vec2(path{a:b}:init{(-4.5,6.7)}:interval{0})",
+		"$bool(path{a:visible}:init{false}:interval{0})",
+		"$bool(path{a:visible}:init{1})",
+		"$str(path{gauge[#]:value}:init{Gauge [#]})",
+		"$num(path{ship:fuel-level}:init{1})",
+		"$num(path{a.b:c}:init{1})",
+	}
+
+	for _, v := range valid {
+		_, err := Parse(v)
+		assert.NoError(t, err, v)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		expression string
+		err        string
+	}{
+		{"$vec2(path{a:b}:init{(1,2)}:intervall{0.1})", "unknown attribute 'intervall' in $vec2"},
+		{"$num(path{a:b}:init{(1,2)})", "init value '(1,2)' of $num is not a number"},
+		{"$num(path{a:b}:init{1}:percent{x})", "percent value 'x' of $num is not a number"},
+		{"$vec2(path{a:b}:init{(X,X)})", "init value '(X,X)' of $vec2 is not a vector"},
+		{"$vec2(path{a:b}:init{(0,0)}:percent{(foo)})", "percent value '(foo)' of $vec2 is not a vector"},
+		{"$vec2(path{a:b}:init{(0,0)}:format{%f})", "format is not supported by $vec2"},
+		{"$bool(path{a:b}:init{yes})", "init value 'yes' of $bool is not a boolean"},
+		{"$boolean(path{a:b}:init{true})", "unknown binding type '$boolean', use '$bool'"},
+		{"$number(path{a:b}:init{1})", "unknown binding type '$number'"},
+		{"$vec2(path{a:b}:interval{0}:op{mul})", "'init' is missing in $vec2"},
+		{"$vec2(init{(4.5,6.7)})", "'path' is missing in $vec2"},
+		{"$vec2(path{path}:init{(4.5,6.7)})", "path 'path' must be on the form path/to:key"},
+		{"$num(path{a b:c}:init{1})", "path 'a b' may not contain whitespace"},
+		{"$num(path{a:}:init{1})", "key '' must be non-empty"},
+		{"$num(path{a:b}:init{1}:op{add})", "unknown operator 'add', expected mul or div"},
+		{"$num(path{a:b}:init{1}:interval{-1})", "interval '-1' is not a positive number"},
+		{"$num(path{a:b}:init{1}:init{2})", "'init' is given more than once"},
+		{"$num(path{a:b}:init{1}:format{%s %s})", "must have exactly one conversion"},
+		{"$num(path{a:b}:init{1}:format{value})", "must have exactly one conversion"},
+		{"$str(path{a:b}:init{x}:format{%f})", "format '%f' of $str has unsupported conversion '%f'"},
+		{"$str(path{a:b}:init{x}:percent{1})", "percent is only supported by $num and $vec2, not $str"},
+		{"$bool(path{a:b}:init{true}:op{mul})", "op is only supported by $num and $vec2, not $bool"},
+		{"$str(path{a:b}:init{x})|$num(path{a:c}:init{1})", "only $str bindings can be concatenated with |, not $num"},
+		{"$num(path{a:b}:init{1}", "is not a binding"},
+		{"$str(path{a:b}:init{x})|", "'' is not a binding"},
+		{"$num(path{a:b}init{1})", "expected ':' between attributes"},
+		{"$num(path{a:b}:init{1)", "missing '}' after init{"},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.expression)
+		assert.ErrorContains(t, err, test.err, test.expression)
+	}
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"os"
+	"strings"
 
 	"github.com/PerMalmberg/du-render/svg2layout/convert"
 	"github.com/spf13/cobra"
@@ -40,17 +41,96 @@ func init() {
 	var (
 		inputFiles []string
 		outputFile string
+		imageUrls  map[string]string
+		tolerance  = convert.DefaultTolerance
+		keepHidden bool
+		variables  map[string]string
+		varFile    string
+		fontFile   string
+		fontMap    map[string]string
+		fontName   string
+		resetSize  bool
+		mergeFonts bool
 	)
 	convert := &cobra.Command{
 		Use: "convert",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			c := convert.NewConverter(outputFile, inputFiles...)
+			vars := map[string]string{}
+			if varFile != "" {
+				f, err := os.Open(varFile)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+
+				if vars, err = convert.ReadVariables(f); err != nil {
+					return err
+				}
+			}
+
+			// Variables on the command line override those in the file
+			for name, value := range variables {
+				vars["--"+strings.TrimPrefix(name, "--")] = value
+			}
+
+			var fonts convert.FontConfig
+			if fontFile != "" {
+				f, err := os.Open(fontFile)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+
+				if fonts, err = convert.ReadFontConfig(f); err != nil {
+					return err
+				}
+			}
+
+			// Fonts on the command line override those in the file
+			if len(fontMap) > 0 && fonts.Aliases == nil {
+				fonts.Aliases = map[string]string{}
+			}
+			for family, font := range fontMap {
+				fonts.Aliases[family] = font
+			}
+
+			if fontName != "" {
+				fonts.DefaultFont = fontName
+			}
+
+			if cmd.Flags().Changed("reset-font-size") {
+				fonts.ResetSize = resetSize
+			}
+
+			if err := fonts.Validate(); err != nil {
+				return err
+			}
+
+			options := convert.Options{
+				ImageUrls:  imageUrls,
+				Tolerance:  tolerance,
+				KeepHidden: keepHidden,
+				Variables:  vars,
+				Fonts:      fonts,
+				MergeFonts: mergeFonts,
+			}
+			c := convert.NewConverter(outputFile, options, inputFiles...)
 			return c.Convert()
 		},
 	}
 
 	convert.Flags().StringArrayVar(&inputFiles, "input", []string{}, "Specify files to be converted")
 	convert.Flags().StringVar(&outputFile, "output", "", "Name of output file")
+	convert.Flags().StringToStringVar(&imageUrls, "image-url", map[string]string{}, "Map an image reference in the SVG to the URL used on the screen, i.e. local.png=https://assets.prod.novaquark.com/...")
+	convert.Flags().Float64Var(&tolerance, "tolerance", tolerance, "Maximum distance, in pixels, between curves and the lines and triangles approximating them")
+	convert.Flags().BoolVar(&keepHidden, "keep-hidden", false, "Emit hidden elements as invisible components instead of skipping them")
+	convert.Flags().StringToStringVar(&variables, "var", map[string]string{}, "Override a CSS custom property, i.e. accent=#ff0000")
+	convert.Flags().StringVar(&varFile, "var-file", "", "File with CSS custom properties overriding those in the SVGs, i.e. --accent: #ff0000;")
+	convert.Flags().StringVar(&fontFile, "font-config", "", "JSON file with additional fonts, font family aliases and the default font")
+	convert.Flags().StringToStringVar(&fontMap, "font-map", map[string]string{}, "Map a font family to a RenderScript font, i.e. monospace=FiraMono")
+	convert.Flags().StringVar(&fontName, "default-font", "", "Font used for texts without a supported font family (default RobotoMono)")
+	convert.Flags().BoolVar(&resetSize, "reset-font-size", false, "Use the default font size, instead of that of the text, when the default font is substituted")
+	convert.Flags().BoolVar(&mergeFonts, "merge-fonts", false, "Merge font sizes when more than 8 fonts are used, instead of failing")
 	convert.MarkFlagRequired("input")
 	convert.MarkFlagRequired("output")
 
@@ -4,15 +4,18 @@ import (
 	"bytes"
 	"encoding/json"
 	"encoding/xml"
-	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/PerMalmberg/du-render/svg2layout/binding"
 	"github.com/PerMalmberg/du-render/svg2layout/layout"
 	"github.com/PerMalmberg/du-render/svg2layout/svg"
 )
@@ -21,20 +24,68 @@ type IConverter interface {
 	Convert() error
 }
 
+// Options controls how the conversion is done
+type Options struct {
+	// ImageUrls maps image references, as found in the SVG, to the URL used in the layout.
+	ImageUrls map[string]string
+	// Tolerance is the maximum distance, in pixels, between a curve and the lines approximating it.
+	Tolerance float64
+	// KeepHidden emits hidden elements as invisible components instead of skipping them.
+	KeepHidden bool
+	// Variables override the values of CSS custom properties, with or without the leading "--".
+	Variables map[string]string
+	// Fonts maps the font families of texts to the fonts of RenderScript
+	Fonts FontConfig
+	// MergeFonts merges font sizes when more fonts are used than RenderScript supports, instead of failing.
+	MergeFonts bool
+}
+
+// DefaultTolerance is used when no curve tolerance is specified
+const DefaultTolerance = 0.5
+
+// ScaffoldingMarker starts the label of layers that are only used while designing; these are never converted.
+const ScaffoldingMarker = "#"
+
 type converter struct {
-	input            []string
-	output           string
-	fonts            IFonts
-	result           layout.Layout
-	commonStyles     map[string]*layout.Style
+	options      Options
+	input        []string
+	output       string
+	fonts        IFonts
+	result       layout.Layout
+	commonStyles map[string]*layout.Style
+	// styleSheet holds the rules of the style elements of the page being translated
+	styleSheet svg.StyleSheet
+	// image is the SVG being translated
+	image *svg.Svg
+	// shadows are the shadows of the filters of the page being translated, nil for unsupported filters.
+	shadows map[string]*layout.Shadow
+	// variables are the custom properties overriding those in the SVGs
+	variables        svg.Declarations
 	pageStyleCounter int
 }
 
-func NewConverter(output string, inputs ...string) IConverter {
+func NewConverter(output string, options Options, inputs ...string) IConverter {
+	if options.Tolerance <= 0 {
+		options.Tolerance = DefaultTolerance
+	}
+
+	names := make([]string, 0, len(options.Variables))
+	for name := range options.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var variables svg.Declarations
+	for _, name := range names {
+		variables = variables.With("--"+strings.TrimPrefix(name, "--"), options.Variables[name])
+	}
+
 	return &converter{
-		input:  inputs,
-		output: output,
-		fonts:  NewFonts(),
+		options:   options,
+		variables: variables,
+		input:     inputs,
+		output:    output,
+		fonts:     NewFonts(options.Fonts),
 		result: layout.Layout{
 			Fonts:  map[string]*layout.Font{},
 			Styles: map[string]*layout.Style{},
@@ -82,52 +133,64 @@ func (c *converter) openFiles() (out *os.File, inp []*os.File, err error) {
 	return
 }
 
-func (c *converter) createFonts(image *svg.Svg) error {
-	for _, layer := range image.Layer {
-		for _, component := range layer.Shape {
-			if text, ok := component.Value.(svg.Text); ok {
-				defaultFont, _ := c.fonts.GetFont(text.Style)
-				for _, span := range text.Span {
-					if len(span.Span) > 0 {
-						return fmt.Errorf("nested text spans not supported")
-					}
-
-					var selectedFont string
-					selectedFont, substituted := c.fonts.GetFont(span.Style)
-					if selectedFont != defaultFont && !substituted {
-						c.fonts.UseFont(selectedFont)
-					} else {
-						c.fonts.UseFont(defaultFont)
-						selectedFont = defaultFont
-					}
-				}
-			}
+func (c *converter) createCommonStyles(pageName string, image *svg.Svg) (err error) {
+	c.styleSheet = nil
+
+	for _, s := range image.Defs.Style {
+		var sheet svg.StyleSheet
+		var ignored []string
+		if sheet, ignored, err = svg.ParseStyleSheet(s.Text); err != nil {
+			return
 		}
-	}
 
-	return nil
-}
+		for _, v := range ignored {
+			fmt.Printf("Warning: unsupported CSS selector '%s' is ignored\n", v)
+		}
 
-func (c *converter) createCommonStyles(pageName string, image *svg.Svg) (err error) {
+		c.styleSheet = append(c.styleSheet, sheet...)
+	}
+
+	// Rules for a single class become common styles, that are preferred when merging equal styles.
+	var names []string
+	classCss := make(map[string]svg.Declarations)
+	for _, rule := range c.styleSheet {
+		for _, selector := range rule.Selectors {
+			if !selector.IsClassOnly() {
+				continue
+			}
 
-	cssStyleExp := regexp.MustCompile(`(?s)\.([a-zA-Z0-9_-]+)\s*{(.*?)}`)
+			name := selector.Classes[0]
+			if _, found := classCss[name]; !found {
+				names = append(names, name)
+			}
 
-	// Parse styles from CSS
-	for _, s := range image.Defs.Style {
-		css := cssStyleExp.FindAllStringSubmatch(s.Text, -1)
-		for _, v := range css {
-			name := v[1]
-			cssData := v[2]
-			style := &layout.Style{}
-			err = style.FromInlineCSS(cssData)
-			if err != nil {
-				return
+			for _, d := range rule.Declarations {
+				classCss[name] = classCss[name].With(d.Property, d.Value)
 			}
+		}
+	}
+
+	// Custom properties used by the common styles are those of the root element
+	rootCss := svg.ParseDeclarations(image.Style).Merge(c.styleSheet.Match("svg", image.Id, strings.Fields(image.Class)))
+
+	for _, name := range names {
+		var css svg.Declarations
+		if css, err = classCss[name].Merge(rootCss.CustomProperties()).Resolve(c.variables); err != nil {
+			// The custom properties may be declared further down the tree, the elements still get the style.
+			fmt.Printf("Skipping common style %s: %v\n", name, err)
+			err = nil
+			continue
+		}
 
-			fullName := c.createPageStyleName(pageName, name)
-			fmt.Printf("Created common style: %s\n", fullName)
-			c.commonStyles[fullName] = style
+		style := &layout.Style{}
+		err = style.FromInlineCSS(css.String())
+		if err != nil {
+			return
 		}
+
+		fullName := c.createPageStyleName(pageName, name)
+		fmt.Printf("Created common style: %s\n", fullName)
+		c.commonStyles[fullName] = style
 	}
 
 	return
@@ -162,13 +225,6 @@ func (c *converter) Convert() (err error) {
 		images[name] = image
 	}
 
-	for name, image := range images {
-		fmt.Printf("Creating fonts from image %v\n", name)
-		c.createFonts(image)
-	}
-
-	c.result.Fonts = c.fonts.GetUsedFonts()
-
 	for name, image := range images {
 		fmt.Printf("Converting image %v\n", name)
 		if err = c.translateSvgToPage(name, image); err != nil {
@@ -176,6 +232,10 @@ func (c *converter) Convert() (err error) {
 		}
 	}
 
+	if err = c.applyFontBudget(); err != nil {
+		return
+	}
+
 	c.replaceStyles()
 
 	var outJson []byte
@@ -192,226 +252,1453 @@ func (c *converter) Convert() (err error) {
 	return
 }
 
+// applyFontBudget sets the fonts of the layout to exactly those used by the components. When there are more than
+// RenderScript supports, the sizes are merged if allowed, otherwise the texts using each font are reported.
+func (c *converter) applyFontBudget() (err error) {
+	pageNames := make([]string, 0, len(c.result.Pages))
+	for name := range c.result.Pages {
+		pageNames = append(pageNames, name)
+	}
+	sort.Strings(pageNames)
+
+	used := map[string]*layout.Font{}
+	usage := map[string]int{}
+	users := map[string][]string{}
+	for _, pageName := range pageNames {
+		for _, comp := range c.result.Pages[pageName].Components {
+			if comp.Font == nil {
+				continue
+			}
+
+			font, found := c.fonts.Font(*comp.Font)
+			if !found {
+				return fmt.Errorf("component on page %s uses unknown font '%s'", pageName, *comp.Font)
+			}
+
+			used[*comp.Font] = font
+			usage[*comp.Font]++
+			if comp.Text != nil {
+				users[*comp.Font] = append(users[*comp.Font], fmt.Sprintf("'%s' on page %s", *comp.Text, pageName))
+			}
+		}
+	}
+
+	replacements := map[string]string{}
+
+	if len(usage) > MaxFonts {
+		if c.options.MergeFonts {
+			replacements, err = consolidateFonts(used, usage, MaxFonts)
+		} else {
+			err = fmt.Errorf("%d fonts are used, at most %d are supported; merge them with --merge-fonts", len(usage), MaxFonts)
+		}
+
+		if err != nil {
+			fonts := make([]string, 0, len(usage))
+			for font := range usage {
+				fonts = append(fonts, font)
+			}
+			sort.Strings(fonts)
+
+			fmt.Println("Font usage:")
+			for _, font := range fonts {
+				fmt.Printf("  %s: %s\n", font, strings.Join(users[font], ", "))
+			}
+			return
+		}
+
+		for _, pageName := range pageNames {
+			comps := c.result.Pages[pageName].Components
+			for i := range comps {
+				if comps[i].Font == nil {
+					continue
+				}
+
+				if replacement, found := replacements[*comps[i].Font]; found {
+					comps[i].Font = &replacement
+				}
+			}
+		}
+
+		merged := make([]string, 0, len(replacements))
+		for font := range replacements {
+			merged = append(merged, font)
+		}
+		sort.Strings(merged)
+
+		for _, font := range merged {
+			fmt.Printf("Merged font %s into %s\n", font, replacements[font])
+		}
+	}
+
+	c.result.Fonts = map[string]*layout.Font{}
+	for font := range usage {
+		if _, merged := replacements[font]; !merged {
+			c.result.Fonts[font] = used[font]
+		}
+	}
+
+	return
+}
+
 func (c *converter) createPageStyleName(pageName, styleName string) string {
 	return fmt.Sprintf("%s-%s", pageName, styleName)
 }
 
+// elementContext holds the state of an element, including what it inherited from its ancestors.
+type elementContext struct {
+	pageName  string
+	layerId   int
+	transform svg.Matrix
+	// style holds the declarations that apply to the element, from its style attribute,
+	// referenced classes and those inherited from its ancestors.
+	style svg.Declarations
+	// notDisplayed is set when the element, or one of its ancestors, has display:none
+	notDisplayed bool
+	// hidden is set when the element isn't rendered, either because it isn't displayed or is invisible.
+	hidden bool
+	// opacity is the opacity of the element multiplied with that of its ancestors
+	opacity float64
+	// shadow is the shadow of a drop shadow filter on the element or one of its ancestors
+	shadow *layout.Shadow
+	// annotations are the bindings and options of the element, one per line as in its description
+	annotations string
+	// id is the id of the element
+	id string
+}
+
+// enterElement creates the context of a child element
+func (c *converter) enterElement(parent elementContext, element string, styled *svg.StyledShape, transformed svg.TransformedShape) (ctx elementContext, err error) {
+	var m svg.Matrix
+	if m, err = svg.ParseTransform(transformed.Transform); err != nil {
+		return
+	}
+
+	ctx = parent
+	ctx.transform = parent.transform.Multiply(m)
+
+	ctx.id = styled.Id
+	if ctx.annotations, err = elementAnnotations(styled); err != nil {
+		return
+	}
+
+	ctx.style = svg.ParseDeclarations(styled.Style)
+
+	// Merge the rules of the style sheet, where classes must be defined
+	classes := strings.Fields(styled.Class)
+	for _, class := range classes {
+		if !c.styleSheet.DefinesClass(class) {
+			err = fmt.Errorf("unknown referenced style: %s", c.createPageStyleName(parent.pageName, class))
+			return
+		}
+	}
+
+	ctx.style = ctx.style.Merge(c.styleSheet.Match(element, styled.Id, classes))
+
+	// Presentation attributes have lower priority than the style
+	ctx.style = ctx.style.Merge(styled.PresentationAttributes())
+
+	ctx.style = ctx.style.Inherit(parent.style)
+
+	if ctx.style, err = ctx.style.Resolve(c.variables); err != nil {
+		return
+	}
+
+	// Display isn't inherited, but nothing within a non-displayed element is rendered.
+	display, _ := ctx.style.Get("display")
+	ctx.notDisplayed = parent.notDisplayed || display == "none" || styled.Display == "none"
+
+	// Visibility is inherited, but unlike display it may be overridden by descendants.
+	visibility, _ := ctx.style.Get("visibility")
+	ctx.hidden = ctx.notDisplayed || visibility == "hidden" || visibility == "collapse"
+
+	// Opacity isn't inherited either, but applies to the group as a whole so it multiplies down the tree.
+	opacity := styled.Opacity
+	if v, found := ctx.style.Get("opacity"); found {
+		opacity = v
+	}
+
+	if opacity != "" {
+		var o float64
+		if o, err = layout.ParseOpacity(opacity); err != nil {
+			return
+		}
+		ctx.opacity *= o
+	}
+
+	// A filter on a group applies to the group as a whole, which is approximated by applying it to each element.
+	if filter, found := ctx.style.Get("filter"); found && filter != "none" {
+		if shadow := c.elementShadow(filter); shadow != nil {
+			ctx.shadow = shadow
+		}
+	}
+
+	return
+}
+
+// skipElement returns true if the element is hidden and hidden elements are not kept.
+func (c *converter) skipElement(ctx elementContext) bool {
+	return ctx.hidden && !c.options.KeepHidden
+}
+
 func (c *converter) translateSvgToPage(pageName string, image *svg.Svg) (err error) {
 	if err = c.createCommonStyles(pageName, image); err != nil {
 		return
 	}
 
 	c.pageStyleCounter = 0
+	c.image = image
+	c.shadows = make(map[string]*layout.Shadow)
 
 	page := &layout.Page{}
 	c.result.Pages[pageName] = page
 
-	for layerId, layer := range image.Layer {
-		layerId = layerId + 1
-		for _, mix := range layer.Shape {
-			if rect, ok := mix.Value.(svg.Rect); ok {
-				pos2 := fmt.Sprintf("(%0.3f,%0.3f)", rect.X+rect.Width, rect.Y+rect.Height)
-
-				comp := layout.Component{
-					Type:    "box",
-					Visible: true,
-					Layer:   layerId,
-					Pos1:    fmt.Sprintf("(%0.3f,%0.3f)", rect.X, rect.Y),
-					Pos2:    &pos2,
-				}
+	// The svg element is the root of the style cascade, custom properties for the whole page are usually declared there.
+	var root elementContext
+	initial := elementContext{
+		pageName:  pageName,
+		transform: svg.Identity(),
+		opacity:   1,
+	}
+	if root, err = c.enterElement(initial, "svg", &image.StyledShape, svg.TransformedShape{}); err != nil {
+		return
+	}
 
-				if radius, ok := image.GetCornerRadiusById(rect.PathEffect); ok {
-					comp.CornerRadius = &radius
-				}
+	layerId := 0
+	for _, layer := range image.Layer {
+		// Excluded layers don't use a layer on the screen
+		if isScaffolding(layer) {
+			continue
+		}
 
-				if err = c.processComponentStyle(&comp, &rect.StyledShape, pageName); err != nil {
-					return
-				}
+		layerId++
+		root.layerId = layerId
+
+		var comps []layout.Component
+		if comps, err = c.translateGroup(root, image, layer); err != nil {
+			return
+		}
+
+		page.Components = append(page.Components, comps...)
+	}
 
-				c.parseBindings(&comp, rect.Description.Text)
+	return
+}
 
-				page.Components = append(page.Components, comp)
+// isScaffolding returns true for layers that are only used while designing, which are excluded from the layout.
+func isScaffolding(group svg.G) bool {
+	if group.GroupMode == "layer" && strings.HasPrefix(group.Label, ScaffoldingMarker) {
+		fmt.Printf("Excluding layer '%s'\n", group.Label)
+		return true
+	}
 
-			} else if text, ok := mix.Value.(svg.Text); ok {
-				// Text is in first span. We only support one span per text.
-				if len(text.Span) != 1 {
-					err = errors.New("only a single span may exist in a text")
-					return
-				}
+	return false
+}
+
+// translateGroup translates the group, and any groups within it, into components on the layer of the context.
+func (c *converter) translateGroup(parent elementContext, image *svg.Svg, group svg.G) (comps []layout.Component, err error) {
+	if isScaffolding(group) {
+		return
+	}
 
-				for _, span := range text.Span {
-					comp := layout.Component{
-						Type:    "text",
-						Layer:   layerId,
-						Visible: true,
-						Pos1:    fmt.Sprintf("(%0.3f,%0.3f)", span.X, span.Y),
-						Text:    &span.Text,
-					}
+	var ctx elementContext
+	// An invisible group may have visible children so only skip groups that are not displayed.
+	if ctx, err = c.enterElement(parent, "g", &group.StyledShape, group.TransformedShape); err != nil || ctx.notDisplayed && !c.options.KeepHidden {
+		return
+	}
+
+	for _, mix := range group.Shape {
+		var shapeComps []layout.Component
+
+		switch shape := mix.Value.(type) {
+		case svg.G:
+			shapeComps, err = c.translateGroup(ctx, image, shape)
+		case svg.Rect:
+			shapeComps, err = c.translateRect(ctx, image, shape)
+		case svg.Text:
+			shapeComps, err = c.translateText(ctx, shape)
+		case svg.Circle:
+			shapeComps, err = c.translateCircle(ctx, shape)
+		case svg.Ellipse:
+			shapeComps, err = c.translateEllipse(ctx, shape)
+		case svg.Line:
+			shapeComps, err = c.translateLine(ctx, shape)
+		case svg.Polyline:
+			shapeComps, err = c.translatePolyline(ctx, shape)
+		case svg.Polygon:
+			shapeComps, err = c.translatePolygon(ctx, shape)
+		case svg.Path:
+			shapeComps, err = c.translatePath(ctx, shape)
+		case svg.Image:
+			shapeComps, err = c.translateImage(ctx, shape)
+		}
 
-					font, _ := c.fonts.GetFont(text.Style)
-					comp.Font = &font
+		if err != nil {
+			return
+		}
 
-					if err = c.processComponentStyle(&comp, &text.StyledShape, pageName); err != nil {
-						return
-					}
+		comps = append(comps, shapeComps...)
+	}
 
-					// Bindings taken from top-level text element
-					c.parseBindings(&comp, text.Description.Text)
+	return
+}
 
-					page.Components = append(page.Components, comp)
+func formatPos(p svg.Point) string {
+	return fmt.Sprintf("(%0.3f,%0.3f)", p.X, p.Y)
+}
 
-				}
-			} else if circle, ok := mix.Value.(svg.Circle); ok {
-				comp := layout.Component{
-					Type:    "circle",
-					Layer:   layerId,
-					Visible: true,
-					Pos1:    fmt.Sprintf("(%0.3f,%0.3f)", circle.X, circle.Y),
-					Radius:  &circle.Radius,
-				}
+// placeBox calculates the corners of the transformed area and the rotation needed for
+// RenderScript to render it in the same place as the SVG does.
+func placeBox(m svg.Matrix, area svg.ShapeArea) (pos1, pos2 svg.Point, rotation float64) {
+	if m.IsRotated() {
+		// RenderScript rotates boxes around their center so place the scaled box around the transformed
+		// center and let the style rotate it into place.
+		if m.IsSkewed() {
+			fmt.Printf("Warning: skewed shape is approximated by a rotated box\n")
+		}
 
-				if err = c.processComponentStyle(&comp, &circle.StyledShape, pageName); err != nil {
-					return
-				}
+		sx, sy := m.Scales()
+		center := m.Apply(svg.Point{X: area.X + area.Width/2, Y: area.Y + area.Height/2})
+		halfWidth := area.Width * sx / 2
+		halfHeight := area.Height * sy / 2
+		pos1 = svg.Point{X: center.X - halfWidth, Y: center.Y - halfHeight}
+		pos2 = svg.Point{X: center.X + halfWidth, Y: center.Y + halfHeight}
+		rotation = m.Rotation()
+	} else {
+		if m.IsMirrored() && (math.Abs(m.A*m.B) > 1e-6 || math.Abs(m.C*m.D) > 1e-6) {
+			fmt.Printf("Warning: mirrored and rotated shape is approximated by its bounding box\n")
+		}
 
-				c.parseBindings(&comp, circle.Description.Text)
+		// Use the bounding box of the transformed corners
+		corners := []svg.Point{
+			m.Apply(svg.Point{X: area.X, Y: area.Y}),
+			m.Apply(svg.Point{X: area.X + area.Width, Y: area.Y}),
+			m.Apply(svg.Point{X: area.X + area.Width, Y: area.Y + area.Height}),
+			m.Apply(svg.Point{X: area.X, Y: area.Y + area.Height}),
+		}
 
-				page.Components = append(page.Components, comp)
-			}
+		pos1 = corners[0]
+		pos2 = corners[0]
+		for _, p := range corners[1:] {
+			pos1.X = math.Min(pos1.X, p.X)
+			pos1.Y = math.Min(pos1.Y, p.Y)
+			pos2.X = math.Max(pos2.X, p.X)
+			pos2.Y = math.Max(pos2.Y, p.Y)
 		}
 	}
 
 	return
 }
 
-func (c *converter) processComponentStyle(comp *layout.Component, shape *svg.StyledShape, pageName string) (err error) {
-	// A component may reference a common style and have local in-line style attributes.
-	local := &layout.Style{}
-	err = local.FromInlineCSS(shape.Style)
-	if err != nil {
+func (c *converter) translateRect(parent elementContext, image *svg.Svg, rect svg.Rect) (comps []layout.Component, err error) {
+	var ctx elementContext
+	if ctx, err = c.enterElement(parent, "rect", &rect.StyledShape, rect.TransformedShape); err != nil || c.skipElement(ctx) {
 		return
 	}
 
-	err = c.setComponentStyle(local, comp, shape, pageName)
-	if err != nil {
-		return
+	var cornerRadius *float64
+	if radius, ok := rectCornerRadius(rect); ok {
+		cornerRadius = &radius
+	} else if radius, ok := image.GetCornerRadiusById(rect.PathEffect); ok {
+		cornerRadius = &radius
 	}
 
+	if comps, err = c.createBox(ctx, rect.ShapeArea, cornerRadius); err == nil {
+		err = c.bindComponents(ctx, comps)
+	}
 	return
 }
 
-func (c *converter) replaceStyles() {
-	// Find styles that are equal and replace the use of them on components with a single instance
-	replacement := make(map[string]*string)
+// rectCornerRadius returns the corner radius from the rx and ry attributes. RenderScript only supports
+// circular corners so elliptical corners are approximated.
+func rectCornerRadius(rect svg.Rect) (radius float64, ok bool) {
+	// Negative values are invalid and treated as not specified
+	valid := func(v *float64) bool {
+		return v != nil && *v >= 0
+	}
 
-	// Add common styles to local ones ensure they are all merged
-	for name, style := range c.commonStyles {
-		c.result.Styles[name] = style
+	var rx, ry float64
+	if valid(rect.Rx) && valid(rect.Ry) {
+		rx, ry = *rect.Rx, *rect.Ry
+	} else if valid(rect.Rx) {
+		rx, ry = *rect.Rx, *rect.Rx
+	} else if valid(rect.Ry) {
+		rx, ry = *rect.Ry, *rect.Ry
+	} else {
+		return
 	}
 
-	keys := make([]string, 0, len(c.result.Styles))
-	for k := range c.result.Styles {
-		keys = append(keys, k)
+	rx = math.Min(rx, rect.Width/2)
+	ry = math.Min(ry, rect.Height/2)
+
+	if rx == 0 || ry == 0 {
+		return
 	}
 
-	sort.Slice(keys, func(i, j int) bool {
-		return keys[i] < keys[j]
-	})
+	if !almostEqual(rx, ry) {
+		fmt.Printf("Warning: elliptical corners (rx: %0.3f, ry: %0.3f) are not supported, using the average radius\n", rx, ry)
+	}
 
-	for outerIx := 0; outerIx < len(keys); outerIx++ {
-		for innerIx := 0; innerIx < len(keys); innerIx++ {
-			outerName := keys[outerIx]
-			innerName := keys[innerIx]
-			if outerName == innerName {
-				continue
-			}
+	// A circular corner can't be larger than the shortest side allows
+	return math.Min((rx+ry)/2, math.Min(rect.Width, rect.Height)/2), true
+}
 
-			selected, replaced := c.prioritizeCommonStyle(outerName, innerName)
+// almostEqual returns true if the values are within 1% of each other
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 0.01*math.Max(math.Abs(a), math.Abs(b))
+}
 
-			if _, found := replacement[selected]; found {
-				continue
-			}
+// createBox creates a box component covering the transformed area.
+func (c *converter) createBox(ctx elementContext, area svg.ShapeArea, cornerRadius *float64) (comps []layout.Component, err error) {
+	pos1, pos2, rotation := placeBox(ctx.transform, area)
 
-			if c.result.Styles[outerName].Equals(c.result.Styles[innerName]) {
-				fmt.Printf("Replacing style %s with %s\n", replaced, selected)
-				replacement[replaced] = &selected
-			}
-		}
+	p2 := formatPos(pos2)
+
+	comp := layout.Component{
+		Type:    "box",
+		Visible: !ctx.hidden,
+		Layer:   ctx.layerId,
+		Pos1:    formatPos(pos1),
+		Pos2:    &p2,
 	}
 
-	// Loop components and update styles to use the replacements.
-	for _, page := range c.result.Pages {
-		for _, comp := range page.Components {
-			if comp.Style != nil {
-				if repl, found := replacement[*comp.Style]; found {
-					comp.Style = repl
-				}
-			}
-		}
+	if cornerRadius != nil {
+		radius := layout.RoundToNearest(*cornerRadius*ctx.transform.ScaleFactor(), 3)
+		comp.CornerRadius = &radius
 	}
 
-	for k := range replacement {
-		delete(c.result.Styles, k)
-		fmt.Printf("Removed style %s\n", k)
+	if err = c.processComponentStyle(ctx, &comp); err != nil {
+		return
 	}
+
+	c.rotateComponentStyle(&comp, rotation)
+
+	comps = append(comps, comp)
+	return
 }
 
-func (c *converter) prioritizeCommonStyle(a, b string) (selected, merged string) {
-	if _, exists := c.commonStyles[a]; exists {
-		return a, b
-	} else if _, exists = c.commonStyles[b]; exists {
-		return b, a
+// textRun is a piece of text with a single style, which becomes a text component of its own.
+type textRun struct {
+	ctx  elementContext
+	text string
+	pos  svg.Point
+	// font is the font resolved from the style of the run
+	font string
+	// preserve is set when the white space of the run is kept as is.
+	preserve bool
+}
+
+// textCursor tracks the current text position while the content of a text element is laid out.
+type textCursor struct {
+	pos svg.Point
+	// placed is set when the position is explicitly given, rather than estimated from preceding text.
+	placed bool
+	// afterSpace is set at the start of the text and after a space, where collapsed white space is removed.
+	afterSpace bool
+	runs       []textRun
+}
+
+// endChunk removes trailing white space at the end of a line, or other piece of text placed on its own,
+// unless white space is preserved.
+func (t *textCursor) endChunk() {
+	if n := len(t.runs); n > 0 && !t.runs[n-1].preserve {
+		t.runs[n-1].text = strings.TrimRight(t.runs[n-1].text, " ")
 	}
-	return a, b
 }
 
-func (c *converter) setComponentStyle(local *layout.Style, comp *layout.Component, styled *svg.StyledShape, pageName string) (err error) {
-	// Get all common names
-	styleNames := strings.Split(strings.Trim(styled.Class, " "), " ")
-	for i := 0; i < len(styleNames); i++ {
-		if styleNames[i] != "" {
-			styleNames[i] = c.createPageStyleName(pageName, styleNames[i])
-		}
+// averageCharWidth is the estimated width of a character, relative to the font size, used to place text that
+// follows other text on the same line since the actual width is only known when rendering.
+const averageCharWidth = 0.6
+
+func (c *converter) translateText(parent elementContext, text svg.Text) (comps []layout.Component, err error) {
+	var ctx elementContext
+	if ctx, err = c.enterElement(parent, "text", &text.StyledShape, text.TransformedShape); err != nil || c.skipElement(ctx) {
+		return
 	}
 
-	// Merge into the local style
-	for _, referencedStyle := range styleNames {
-		if referencedStyle != "" {
-			commonStyle, ok := c.commonStyles[referencedStyle]
-			if !ok {
-				err = fmt.Errorf("unknown referenced style: %s", referencedStyle)
-				return
-			} else {
-				local.MergeInto(commonStyle)
-			}
-		}
+	var content []svg.TextContent
+	if content, err = text.Content(); err != nil {
+		return
 	}
 
-	componentStyleName := fmt.Sprintf("%s-%d", c.createPageStyleName(pageName, comp.Type), c.pageStyleCounter)
-	c.pageStyleCounter++
-	fmt.Printf("Created component style: %s\n", componentStyleName)
-	comp.Style = &componentStyleName
-	c.result.Styles[componentStyleName] = local
+	cursor := &textCursor{placed: true, afterSpace: true}
+	if cursor.pos, _, err = text.Move(svg.Point{}); err != nil {
+		return
+	}
 
-	return
-}
+	// Each line, and each differently styled run within a line, is a component of its own.
+	if err = c.layoutText(ctx, content, preservesSpace(false, text.Space, ctx), cursor); err != nil {
+		return
+	}
+	cursor.endChunk()
 
-func (c *converter) parseBindings(comp *layout.Component, potentialBindings string) {
-	// Bindings are expected to have this format:
-	// propertyName:$keyword(...) where propertyName is the lower-case name used in the Json layout.
-	exp := regexp.MustCompile(`^([a-z0-9]+):(\$[a-zA-Z0-9]+\(.+?\))$`)
+	for _, run := range cursor.runs {
+		if !run.ctx.transform.IsUniform() || math.Abs(run.ctx.transform.ScaleFactor()-1) > 1e-6 {
+			fmt.Printf("Warning: scaling and skewing of texts is not supported, font size is kept: '%s'\n", run.text)
+		}
 
-	comp.Bindings = make(map[string]string)
+		if run.ctx.transform.IsMirrored() {
+			fmt.Printf("Warning: mirroring of texts is not supported, the text is not mirrored: '%s'\n", run.text)
+		}
 
-	for _, part := range strings.Split(potentialBindings, "\n") {
-		bindings := exp.FindAllStringSubmatch(part, -1)
+		content := run.text
+		comp := layout.Component{
+			Type:    "text",
+			Layer:   run.ctx.layerId,
+			Visible: !run.ctx.hidden,
+			Pos1:    formatPos(run.ctx.transform.Apply(run.pos)),
+			Text:    &content,
+		}
 
-		for _, v := range bindings {
-			property := v[1]
-			binding := v[2]
-			comp.Bindings[property] = binding
+		font := run.font
+		comp.Font = &font
+
+		if err = c.processComponentStyle(run.ctx, &comp); err != nil {
+			return
 		}
+
+		// RenderScript rotates texts around their anchor, i.e. the same point as SVG does.
+		c.rotateComponentStyle(&comp, run.ctx.transform.Rotation())
+
+		comps = append(comps, comp)
 	}
+
+	// Bindings taken from top-level text element, where indexed bindings target a single line or run.
+	err = c.bindComponents(ctx, comps)
+
+	return
+}
+
+// preservesSpace returns true if the white space of an element is kept as is, either inherited from the parent,
+// given by xml:space or by the white-space property which takes precedence.
+func preservesSpace(parent bool, xmlSpace string, ctx elementContext) bool {
+	preserve := parent
+	if xmlSpace != "" {
+		preserve = xmlSpace == "preserve"
+	}
+
+	if whiteSpace, found := ctx.style.Get("white-space"); found {
+		preserve = whiteSpace == "pre" || whiteSpace == "pre-wrap" || whiteSpace == "break-spaces"
+	}
+
+	return preserve
+}
+
+// layoutText collects the runs of the content, positioned as given by the spans or following the preceding run.
+func (c *converter) layoutText(ctx elementContext, content []svg.TextContent, preserve bool, cursor *textCursor) (err error) {
+	for _, part := range content {
+		if part.Span == nil {
+			text := svg.NormalizeSpace(part.Text, preserve)
+			if !preserve && cursor.afterSpace {
+				text = strings.TrimPrefix(text, " ")
+			}
+
+			if text == "" {
+				continue
+			}
+
+			// White space between spans, such as the indentation of the lines, takes up room but isn't a component.
+			if strings.TrimSpace(text) == "" {
+				cursor.pos.X += estimatedTextWidth(ctx, text)
+				cursor.afterSpace = true
+				continue
+			}
+
+			if !cursor.placed {
+				fmt.Printf("Warning: text '%s' follows other text without a position of its own, its position is estimated\n", text)
+			}
+
+			font, _ := c.fonts.GetFont(ctx.style.String())
+			cursor.runs = append(cursor.runs, textRun{ctx: ctx, text: text, pos: cursor.pos, font: font, preserve: preserve})
+			cursor.pos.X += estimatedTextWidth(ctx, text)
+			cursor.placed = false
+			cursor.afterSpace = strings.HasSuffix(text, " ")
+			continue
+		}
+
+		var spanCtx elementContext
+		if spanCtx, err = c.enterElement(ctx, "tspan", &part.Span.StyledShape, svg.TransformedShape{}); err != nil {
+			return
+		}
+
+		if c.skipElement(spanCtx) {
+			continue
+		}
+
+		var hasX bool
+		if cursor.pos, hasX, err = part.Span.Move(cursor.pos); err != nil {
+			return
+		}
+
+		if hasX {
+			cursor.endChunk()
+			cursor.placed = true
+		}
+
+		var spanContent []svg.TextContent
+		if spanContent, err = part.Span.Content(); err != nil {
+			return
+		}
+
+		if err = c.layoutText(spanCtx, spanContent, preservesSpace(preserve, part.Span.Space, spanCtx), cursor); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// estimatedTextWidth returns the approximate width of the text in the font size of the context.
+func estimatedTextWidth(ctx elementContext, text string) float64 {
+	size := float64(defaultSize)
+	if v, found := ctx.style.Get("font-size"); found {
+		if f, err := strconv.ParseFloat(strings.TrimSuffix(v, "px"), 64); err == nil {
+			size = f
+		}
+	}
+
+	return float64(utf8.RuneCountInString(text)) * size * averageCharWidth
+}
+
+func (c *converter) translateCircle(parent elementContext, circle svg.Circle) (comps []layout.Component, err error) {
+	var ctx elementContext
+	if ctx, err = c.enterElement(parent, "circle", &circle.StyledShape, circle.TransformedShape); err != nil || c.skipElement(ctx) {
+		return
+	}
+
+	if comps, err = c.createCircle(ctx, svg.Point{X: circle.X, Y: circle.Y}, circle.Radius); err == nil {
+		err = c.bindComponents(ctx, comps)
+	}
+	return
+}
+
+func (c *converter) translateEllipse(parent elementContext, ellipse svg.Ellipse) (comps []layout.Component, err error) {
+	var ctx elementContext
+	if ctx, err = c.enterElement(parent, "ellipse", &ellipse.StyledShape, ellipse.TransformedShape); err != nil || c.skipElement(ctx) {
+		return
+	}
+
+	if almostEqual(ellipse.Rx, ellipse.Ry) {
+		if comps, err = c.createCircle(ctx, svg.Point{X: ellipse.X, Y: ellipse.Y}, (ellipse.Rx+ellipse.Ry)/2); err == nil {
+			err = c.bindComponents(ctx, comps)
+		}
+		return
+	}
+
+	// RenderScript has no ellipses, approximate it with a box with fully rounded corners.
+	fmt.Printf("Warning: ellipse (rx: %0.3f, ry: %0.3f) is approximated by a rounded box\n", ellipse.Rx, ellipse.Ry)
+
+	area := svg.ShapeArea{
+		PositionalShape: svg.PositionalShape{X: ellipse.X - ellipse.Rx, Y: ellipse.Y - ellipse.Ry},
+		Width:           ellipse.Rx * 2,
+		Height:          ellipse.Ry * 2,
+	}
+
+	radius := math.Min(ellipse.Rx, ellipse.Ry)
+	if comps, err = c.createBox(ctx, area, &radius); err == nil {
+		err = c.bindComponents(ctx, comps)
+	}
+	return
+}
+
+// createCircle creates a circle component at the transformed center
+func (c *converter) createCircle(ctx elementContext, center svg.Point, radius float64) (comps []layout.Component, err error) {
+	if !ctx.transform.IsUniform() {
+		fmt.Printf("Warning: circle is transformed into an ellipse, which is not supported. Scaling the radius by the geometric mean of the scales.\n")
+	}
+
+	radius *= ctx.transform.ScaleFactor()
+
+	comp := layout.Component{
+		Type:    "circle",
+		Layer:   ctx.layerId,
+		Visible: !ctx.hidden,
+		Pos1:    formatPos(ctx.transform.Apply(center)),
+		Radius:  &radius,
+	}
+
+	if err = c.processComponentStyle(ctx, &comp); err != nil {
+		return
+	}
+
+	comps = append(comps, comp)
+	return
+}
+
+func (c *converter) translateLine(parent elementContext, line svg.Line) (comps []layout.Component, err error) {
+	var ctx elementContext
+	if ctx, err = c.enterElement(parent, "line", &line.StyledShape, line.TransformedShape); err != nil || c.skipElement(ctx) {
+		return
+	}
+
+	if comps, err = c.createLines(ctx, []svg.Point{{X: line.X1, Y: line.Y1}, {X: line.X2, Y: line.Y2}}); err == nil {
+		err = c.bindComponents(ctx, comps)
+	}
+	return
+}
+
+func (c *converter) translatePolyline(parent elementContext, polyline svg.Polyline) (comps []layout.Component, err error) {
+	var ctx elementContext
+	if ctx, err = c.enterElement(parent, "polyline", &polyline.StyledShape, polyline.TransformedShape); err != nil || c.skipElement(ctx) {
+		return
+	}
+
+	var points []svg.Point
+	if points, err = svg.ParsePoints(polyline.Points); err != nil {
+		return
+	}
+
+	if len(points) < 2 {
+		err = fmt.Errorf("polyline must have at least two points: '%s'", polyline.Points)
+		return
+	}
+
+	if comps, err = c.createLines(ctx, points); err == nil {
+		err = c.bindComponents(ctx, comps)
+	}
+	return
+}
+
+func (c *converter) translateImage(parent elementContext, image svg.Image) (comps []layout.Component, err error) {
+	var ctx elementContext
+	if ctx, err = c.enterElement(parent, "image", &image.StyledShape, image.TransformedShape); err != nil || c.skipElement(ctx) {
+		return
+	}
+
+	options := parseOptions(ctx.annotations)
+
+	var url string
+	if url, err = c.imageUrl(image.Url(), options); err != nil {
+		return
+	}
+
+	if image.PreserveAspectRatio != "none" {
+		fmt.Printf("Warning: preserveAspectRatio is not supported, image '%s' is stretched to fill %0.3fx%0.3f\n", image.Url(), image.Width, image.Height)
+	}
+
+	if ctx.transform.IsMirrored() {
+		fmt.Printf("Warning: mirroring of images is not supported, image '%s' is not mirrored\n", image.Url())
+	}
+
+	pos1, pos2, rotation := placeBox(ctx.transform, image.ShapeArea)
+	dimensions := formatPos(svg.Point{X: pos2.X - pos1.X, Y: pos2.Y - pos1.Y})
+
+	comp := layout.Component{
+		Type:       "image",
+		Layer:      ctx.layerId,
+		Visible:    !ctx.hidden,
+		Pos1:       formatPos(pos1),
+		Url:        &url,
+		Dimensions: &dimensions,
+	}
+
+	// Sub image, in pixels of the source image, if specified
+	if sub, ok := options["sub"]; ok {
+		comp.Sub = &sub
+	}
+
+	if subDimensions, ok := options["subDimensions"]; ok {
+		comp.SubDimensions = &subDimensions
+	}
+
+	if (comp.Sub == nil) != (comp.SubDimensions == nil) {
+		err = fmt.Errorf("both 'sub' and 'subDimensions' must be specified for image '%s'", image.Url())
+		return
+	}
+
+	if err = c.processComponentStyle(ctx, &comp); err != nil {
+		return
+	}
+
+	c.rotateComponentStyle(&comp, rotation)
+
+	comps = append(comps, comp)
+	err = c.bindComponents(ctx, comps)
+	return
+}
+
+// imageUrl determines the URL to use for the image, in order of priority:
+// a 'url' option in the description, the configured mapping or the reference itself when it is a web address.
+func (c *converter) imageUrl(href string, options map[string]string) (url string, err error) {
+	if u, ok := options["url"]; ok {
+		return u, nil
+	}
+
+	if u, ok := c.options.ImageUrls[href]; ok {
+		return u, nil
+	}
+
+	if u, ok := c.options.ImageUrls[filepath.Base(href)]; ok {
+		return u, nil
+	}
+
+	if strings.HasPrefix(href, "http://") || strings.HasPrefix(href, "https://") {
+		return href, nil
+	}
+
+	err = fmt.Errorf("image '%s' has no URL that can be loaded by the screen, map it to one or add a 'url:' line to its description", href)
+	return
+}
+
+func (c *converter) translatePolygon(parent elementContext, polygon svg.Polygon) (comps []layout.Component, err error) {
+	var ctx elementContext
+	if ctx, err = c.enterElement(parent, "polygon", &polygon.StyledShape, polygon.TransformedShape); err != nil || c.skipElement(ctx) {
+		return
+	}
+
+	var points []svg.Point
+	if points, err = svg.ParsePoints(polygon.Points); err != nil {
+		return
+	}
+
+	// A polygon is a closed path of straight lines
+	subPath := svg.SubPath{Closed: true}
+	for i := range points {
+		subPath.Segments = append(subPath.Segments, svg.Segment{
+			Kind:  svg.LineSegment,
+			Start: points[i],
+			End:   points[(i+1)%len(points)],
+		})
+	}
+
+	if comps, err = c.createPathShapes(ctx, []svg.SubPath{subPath}); err == nil {
+		err = c.bindComponents(ctx, comps)
+	}
+	return
+}
+
+func (c *converter) translatePath(parent elementContext, path svg.Path) (comps []layout.Component, err error) {
+	var ctx elementContext
+	if ctx, err = c.enterElement(parent, "path", &path.StyledShape, path.TransformedShape); err != nil || c.skipElement(ctx) {
+		return
+	}
+
+	var subPaths []svg.SubPath
+	if subPaths, err = svg.ParsePathData(path.D); err != nil {
+		return
+	}
+
+	if comps, err = c.createPathShapes(ctx, subPaths); err == nil {
+		err = c.bindComponents(ctx, comps)
+	}
+	return
+}
+
+// createPathShapes creates components for the sub paths; the fill is triangulated into triangles and quads
+// and the stroke is made of lines. Curves of unfilled paths are made of bezier components where possible.
+func (c *converter) createPathShapes(ctx elementContext, subPaths []svg.SubPath) (comps []layout.Component, err error) {
+	// A single, straight, segment is represented by a line
+	if len(subPaths) == 1 && len(subPaths[0].Segments) == 1 && subPaths[0].Segments[0].Kind == svg.LineSegment {
+		return c.createLines(ctx, subPaths[0].Points())
+	}
+
+	fill, _ := ctx.style.Get("fill")
+	stroke, _ := ctx.style.Get("stroke")
+	filled := fill != "" && fill != "none"
+	stroked := stroke != "" && stroke != "none"
+
+	if !filled && !stroked {
+		fmt.Printf("Warning: path has neither fill nor stroke, skipping it\n")
+		return
+	}
+
+	// Curves are flattened before the transform is applied so adjust the tolerance accordingly
+	tolerance := c.options.Tolerance
+	if scale := ctx.transform.ScaleFactor(); scale > 0 {
+		tolerance /= scale
+	}
+
+	flattened := make([][]svg.Point, len(subPaths))
+	for i, sp := range subPaths {
+		flattened[i] = flattenSubPath(sp, tolerance)
+	}
+
+	// Holes are not supported, so sub paths within another one are not filled as that would paint over the hole.
+	holes := make([]bool, len(subPaths))
+	if filled {
+		for i := range flattened {
+			for j := range flattened {
+				if i != j && polygonInside(flattened[i], flattened[j]) {
+					holes[i] = true
+					break
+				}
+			}
+
+			if holes[i] {
+				fmt.Printf("Warning: holes are not supported, sub path %d of path '%s' is within another sub path and is not filled\n", i+1, ctx.id)
+			}
+		}
+	}
+
+	// The fill must not have a stroke as it would outline each triangle, and lines only use the stroke.
+	fillCtx := ctx
+	fillCtx.style = ctx.style.With("stroke", "none")
+	strokeCtx := ctx
+	strokeCtx.style = ctx.style.With("fill", "none")
+
+	for i, sp := range subPaths {
+		points := flattened[i]
+
+		if filled && !holes[i] {
+			var triangles []triangle
+			if triangles, err = triangulate(points); err != nil {
+				return
+			}
+
+			quads, triangles := mergeTriangles(triangles)
+
+			for _, q := range quads {
+				var comp layout.Component
+				if comp, err = c.createPolygonComponent(fillCtx, "quad", q[:]); err != nil {
+					return
+				}
+				comps = append(comps, comp)
+			}
+
+			for _, t := range triangles {
+				var comp layout.Component
+				if comp, err = c.createPolygonComponent(fillCtx, "triangle", t[:]); err != nil {
+					return
+				}
+				comps = append(comps, comp)
+			}
+		}
+
+		if stroked && !filled {
+			var strokes []layout.Component
+			if strokes, err = c.createStroke(strokeCtx, sp, tolerance); err != nil {
+				return
+			}
+			comps = append(comps, strokes...)
+		} else if stroked {
+			var lines []layout.Component
+			if lines, err = c.createLines(strokeCtx, points); err != nil {
+				return
+			}
+			comps = append(comps, lines...)
+		}
+	}
+
+	return
+}
+
+// createStroke creates a bezier component for each quadratic curve, and each cubic curve that can be approximated
+// by quadratic curves, of the sub path. The remaining segments are made of lines.
+func (c *converter) createStroke(ctx elementContext, sp svg.SubPath, tolerance float64) (comps []layout.Component, err error) {
+	// Segments not represented by beziers are collected and flattened into lines
+	var pending []svg.Segment
+
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+
+		lines, err := c.createLines(ctx, flattenSubPath(svg.SubPath{Segments: pending}, tolerance))
+		comps = append(comps, lines...)
+		pending = nil
+		return err
+	}
+
+	for _, seg := range sp.Segments {
+		var curves []quadratic
+
+		switch seg.Kind {
+		case svg.QuadraticSegment:
+			curves = []quadratic{{seg.Start, seg.Control1, seg.End}}
+		case svg.CubicSegment:
+			curves, _ = cubicToQuadratics(seg.Start, seg.Control1, seg.Control2, seg.End, tolerance)
+		}
+
+		if len(curves) == 0 {
+			pending = append(pending, seg)
+			continue
+		}
+
+		if err = flush(); err != nil {
+			return
+		}
+
+		for _, q := range curves {
+			var comp layout.Component
+			if comp, err = c.createBezier(ctx, q); err != nil {
+				return
+			}
+			comps = append(comps, comp)
+		}
+	}
+
+	err = flush()
+	return
+}
+
+// createBezier creates a bezier component from the quadratic curve.
+func (c *converter) createBezier(ctx elementContext, q quadratic) (comp layout.Component, err error) {
+	pos2 := formatPos(ctx.transform.Apply(q[1]))
+	pos3 := formatPos(ctx.transform.Apply(q[2]))
+
+	comp = layout.Component{
+		Type:    "bezier",
+		Layer:   ctx.layerId,
+		Visible: !ctx.hidden,
+		Pos1:    formatPos(ctx.transform.Apply(q[0])),
+		Pos2:    &pos2,
+		Pos3:    &pos3,
+	}
+
+	err = c.processComponentStyle(ctx, &comp)
+	return
+}
+
+// createPolygonComponent creates a triangle or quad component from the corners.
+func (c *converter) createPolygonComponent(ctx elementContext, compType string, corners []svg.Point) (comp layout.Component, err error) {
+	positions := make([]string, len(corners))
+	for i, p := range corners {
+		positions[i] = formatPos(ctx.transform.Apply(p))
+	}
+
+	comp = layout.Component{
+		Type:    compType,
+		Layer:   ctx.layerId,
+		Visible: !ctx.hidden,
+		Pos1:    positions[0],
+		Pos2:    &positions[1],
+		Pos3:    &positions[2],
+	}
+
+	if len(positions) > 3 {
+		comp.Pos4 = &positions[3]
+	}
+
+	err = c.processComponentStyle(ctx, &comp)
+	return
+}
+
+// createLines creates one line component per segment between the points.
+func (c *converter) createLines(ctx elementContext, points []svg.Point) (comps []layout.Component, err error) {
+	for i := 1; i < len(points); i++ {
+		pos2 := formatPos(ctx.transform.Apply(points[i]))
+
+		comp := layout.Component{
+			Type:    "line",
+			Layer:   ctx.layerId,
+			Visible: !ctx.hidden,
+			Pos1:    formatPos(ctx.transform.Apply(points[i-1])),
+			Pos2:    &pos2,
+		}
+
+		if err = c.processComponentStyle(ctx, &comp); err != nil {
+			return
+		}
+
+		comps = append(comps, comp)
+	}
+
+	return
+}
+
+func (c *converter) processComponentStyle(ctx elementContext, comp *layout.Component) (err error) {
+	// The context holds the in-line style attributes merged with referenced common styles and inherited properties.
+	local := &layout.Style{}
+	err = local.FromInlineCSS(ctx.style.String())
+	if err != nil {
+		return
+	}
+
+	// Stroke width scales with the transform
+	if local.Stroke != nil {
+		stroke := *local.Stroke
+		stroke.Distance = layout.RoundToNearest(stroke.Distance*ctx.transform.ScaleFactor(), 3)
+		stroke.Color.Alpha = layout.RoundToNearest(stroke.Color.Alpha*ctx.opacity, 3)
+		local.Stroke = &stroke
+	}
+
+	// Opacity of the element and its ancestors applies to both the fill and the stroke
+	if local.Fill != nil {
+		fill := *local.Fill
+		fill.Alpha = layout.RoundToNearest(fill.Alpha*ctx.opacity, 3)
+		local.Fill = &fill
+	}
+
+	// Alignment only applies to texts
+	if comp.Type == "text" {
+		align := layout.AlignFromStyle(ctx.style.String())
+		local.Align = &align
+	}
+
+	// The shadow radius scales with the transform, and the shadow fades with the element
+	if ctx.shadow != nil {
+		shadow := *ctx.shadow
+		shadow.Distance = layout.RoundToNearest(shadow.Distance*ctx.transform.ScaleFactor(), 3)
+		shadow.Color.Alpha = layout.RoundToNearest(shadow.Color.Alpha*ctx.opacity, 3)
+		local.Shadow = &shadow
+	}
+
+	c.setComponentStyle(local, comp, ctx.pageName)
+
+	return
+}
+
+// rotateComponentStyle sets the rotation of the component's own style.
+// The rotation is given in SVG degrees, i.e. clockwise, while DU rotates counter-clockwise.
+func (c *converter) rotateComponentStyle(comp *layout.Component, svgDegrees float64) {
+	rotation := layout.RoundToNearest(-svgDegrees, 3)
+	if rotation == 0 {
+		return
+	}
+
+	c.result.Styles[*comp.Style].Rotation = &rotation
+}
+
+func (c *converter) replaceStyles() {
+	// Find styles that are equal and replace the use of them on components with a single instance
+	replacement := make(map[string]*string)
+
+	// Add common styles to local ones ensure they are all merged
+	for name, style := range c.commonStyles {
+		c.result.Styles[name] = style
+	}
+
+	keys := make([]string, 0, len(c.result.Styles))
+	for k := range c.result.Styles {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+
+	keys = append(keys, c.createTextVariants(keys)...)
+
+	for outerIx := 0; outerIx < len(keys); outerIx++ {
+		for innerIx := 0; innerIx < len(keys); innerIx++ {
+			outerName := keys[outerIx]
+			innerName := keys[innerIx]
+			if outerName == innerName {
+				continue
+			}
+
+			selected, replaced := c.prioritizeCommonStyle(outerName, innerName)
+
+			if _, found := replacement[selected]; found {
+				continue
+			}
+
+			if c.result.Styles[outerName].Equals(c.result.Styles[innerName]) {
+				fmt.Printf("Replacing style %s with %s\n", replaced, selected)
+				replacement[replaced] = &selected
+			}
+		}
+	}
+
+	// Loop components and update styles to use the replacements.
+	for _, page := range c.result.Pages {
+		for i := range page.Components {
+			comp := &page.Components[i]
+			if comp.Style != nil {
+				if repl, found := replacement[*comp.Style]; found {
+					comp.Style = repl
+				}
+			}
+		}
+	}
+
+	for k := range replacement {
+		delete(c.result.Styles, k)
+		fmt.Printf("Removed style %s\n", k)
+	}
+}
+
+// createTextVariants creates a variant of each common style that equals a text style apart from the alignment,
+// which common styles don't carry, so that texts using a class still map to a common style.
+func (c *converter) createTextVariants(keys []string) (variants []string) {
+	commonNames := make([]string, 0, len(c.commonStyles))
+	for name := range c.commonStyles {
+		commonNames = append(commonNames, name)
+	}
+	sort.Strings(commonNames)
+
+	for _, key := range keys {
+		style := c.result.Styles[key]
+		if _, common := c.commonStyles[key]; common || style.Align == nil {
+			continue
+		}
+
+		for _, name := range commonNames {
+			variant := *c.commonStyles[name]
+			if variant.Align != nil {
+				continue
+			}
+
+			variant.Align = style.Align
+			variantName := fmt.Sprintf("%s-text-%s", name, strings.Replace(*style.Align, ",", "-", 1))
+			if _, exists := c.commonStyles[variantName]; exists || !variant.Equals(style) {
+				continue
+			}
+
+			fmt.Printf("Created common style: %s\n", variantName)
+			c.commonStyles[variantName] = &variant
+			c.result.Styles[variantName] = &variant
+			variants = append(variants, variantName)
+		}
+	}
+
+	return
+}
+
+func (c *converter) prioritizeCommonStyle(a, b string) (selected, merged string) {
+	if _, exists := c.commonStyles[a]; exists {
+		return a, b
+	} else if _, exists = c.commonStyles[b]; exists {
+		return b, a
+	}
+	return a, b
+}
+
+func (c *converter) setComponentStyle(local *layout.Style, comp *layout.Component, pageName string) {
+	componentStyleName := fmt.Sprintf("%s-%d", c.createPageStyleName(pageName, comp.Type), c.pageStyleCounter)
+	c.pageStyleCounter++
+	fmt.Printf("Created component style: %s\n", componentStyleName)
+	comp.Style = &componentStyleName
+	c.result.Styles[componentStyleName] = local
+}
+
+// bindComponents adds the bindings of the element to the components created from it. A binding applies to all
+// of the components unless it is indexed, such as pos2[3]:$vec2(...), which only applies to the third component.
+// Properties that can't be bound on a component are errors, except for unindexed bindings of elements resulting in
+// several components, which are only applied to the components supporting them.
+func (c *converter) bindComponents(ctx elementContext, comps []layout.Component) (err error) {
+	// Unindexed properties not supported by some of the components
+	var dropped []string
+
+	for i := range comps {
+		var indexed map[string]bool
+		if indexed, err = c.parseBindings(ctx, &comps[i], ctx.annotations, i+1); err != nil {
+			return
+		}
+
+		for property := range comps[i].Bindings {
+			if layout.IsBindable(comps[i].Type, property) {
+				continue
+			}
+
+			if indexed[property] || len(comps) == 1 {
+				return fmt.Errorf("'%s' of element '%s' can't be bound on a %s", property, ctx.id, comps[i].Type)
+			}
+
+			delete(comps[i].Bindings, property)
+			dropped = append(dropped, property)
+		}
+	}
+
+	sort.Strings(dropped)
+	for _, property := range dropped {
+		if !c.boundOnAny(comps, property) {
+			return fmt.Errorf("'%s' of element '%s' can't be bound on any of its components", property, ctx.id)
+		}
+	}
+
+	return
+}
+
+// boundOnAny returns true if the property is bound on any of the components
+func (c *converter) boundOnAny(comps []layout.Component, property string) bool {
+	for _, comp := range comps {
+		if _, found := comp.Bindings[property]; found {
+			return true
+		}
+	}
+	return false
+}
+
+// dataPrefix starts the names of the data attributes holding bindings and options, such as data-du-pos1.
+const dataPrefix = "du-"
+
+var annotationNameExp = regexp.MustCompile(`^\s*([a-zA-Z0-9_]+)(?:\[([0-9]+)\])?\s*:`)
+var dataIndexExp = regexp.MustCompile(`^(.+)-([0-9]+)$`)
+
+// elementAnnotations combines the lines of the description with the data-du-* attributes of the element, where
+// data-du-text-2="$str(...)" is the same as the line text[2]:$str(...). Defining an entry in both is an error.
+func elementAnnotations(styled *svg.StyledShape) (annotations string, err error) {
+	lines := strings.Split(styled.Description.Text, "\n")
+
+	defined := map[string]bool{}
+	for _, line := range lines {
+		if m := annotationNameExp.FindStringSubmatch(line); m != nil {
+			defined[m[1]+"["+m[2]+"]"] = true
+		}
+	}
+
+	data := styled.DataAttributes()
+	names := make([]string, 0, len(data))
+	for name := range data {
+		if strings.HasPrefix(name, dataPrefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		entry := strings.TrimPrefix(name, dataPrefix)
+		property, index := entry, ""
+		if m := dataIndexExp.FindStringSubmatch(entry); m != nil {
+			property, index = m[1], m[2]
+			entry = property + "[" + index + "]"
+		}
+
+		if defined[property+"["+index+"]"] {
+			err = fmt.Errorf("'%s' of element '%s' is defined both by data-%s and its description", entry, styled.Id, name)
+			return
+		}
+		defined[property+"["+index+"]"] = true
+
+		lines = append(lines, entry+":"+strings.TrimSpace(data[name]))
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+var bindingLineExp = regexp.MustCompile(`^([a-zA-Z0-9_]+)(?:\[([0-9]+)\])?:(\$[a-zA-Z0-9]+\(.+?\))$`)
+
+// bindingLikeExp matches lines that are meant to be bindings, to report those that are malformed
+var bindingLikeExp = regexp.MustCompile(`^([a-zA-Z0-9_]+)(?:\[[0-9]*\])?\s*:\s*\$[a-zA-Z]`)
+
+// parseBindings sets the bindings of the component, returning those specifically indexed for it.
+func (c *converter) parseBindings(ctx elementContext, comp *layout.Component, potentialBindings string, index int) (indexed map[string]bool, err error) {
+	// Bindings are expected to have this format:
+	// propertyName[index]:$keyword(...) where propertyName is the name used in the Json layout
+	// and the optional index is the 1-based index of the component created from the element.
+	comp.Bindings = make(map[string]string)
+	// Indexed bindings take precedence over those that apply to all components
+	indexed = make(map[string]bool)
+
+	for _, part := range strings.Split(potentialBindings, "\n") {
+		part = strings.TrimSpace(part)
+		v := bindingLineExp.FindStringSubmatch(part)
+		if v == nil {
+			if m := bindingLikeExp.FindStringSubmatch(part); m != nil {
+				return nil, fmt.Errorf("invalid binding '%s' of element '%s', expected %s:$type(...)", part, ctx.id, m[1])
+			}
+			continue
+		}
+
+		property := v[1]
+		var parsed binding.Expression
+		if parsed, err = binding.Parse(v[3]); err != nil {
+			return nil, fmt.Errorf("invalid binding of '%s' of element '%s': %v", property, ctx.id, err)
+		}
+
+		if v[2] != "" {
+			if n, _ := strconv.Atoi(v[2]); n != index {
+				continue
+			}
+			indexed[property] = true
+		} else if indexed[property] {
+			continue
+		}
+
+		// Boxes and images are placed around their transformed center and rotated by the style, which
+		// a transformed vector can't follow.
+		if ctx.transform.IsRotated() && (comp.Type == "box" || comp.Type == "image") && isTransformedBinding(property, parsed) {
+			return nil, fmt.Errorf("'%s' of element '%s' can't be bound with $vec2 as the element is rotated", property, ctx.id)
+		}
+
+		comp.Bindings[property] = transformBinding(property, v[3], parsed, ctx.transform)
+	}
+
+	return
+}
+
+// parseOptions extracts non-binding options from the description; these are lines on the format name:value
+func parseOptions(description string) map[string]string {
+	exp := regexp.MustCompile(`^\s*([a-zA-Z0-9_]+)\s*:\s*(.+?)\s*$`)
+
+	options := make(map[string]string)
+
+	for _, line := range strings.Split(description, "\n") {
+		if m := exp.FindStringSubmatch(line); m != nil && !strings.HasPrefix(m[2], "$") {
+			options[m[1]] = m[2]
+		}
+	}
+
+	return options
+}
+
+var vec2ValueExp = regexp.MustCompile(`(init|percent)\{\(\s*([^,)]+?)\s*,\s*([^,)]+?)\s*\)\}`)
+
+// isTransformedBinding returns true for $vec2 bindings of positions and dimensions. Multipliers, i.e. op{mul} and
+// op{div}, and the sub offset, which is in image coordinates, are not transformed.
+func isTransformedBinding(property string, parsed binding.Expression) bool {
+	if len(parsed) != 1 || parsed[0].Kind != binding.Vec2 || parsed[0].Op != "" {
+		return false
+	}
+
+	switch property {
+	case "pos1", "pos2", "pos3", "pos4", "dimensions", "subDimensions":
+		return true
+	}
+
+	return false
+}
+
+// transformBinding applies the transform to the init and percent vectors of a $vec2 binding as those are specified
+// in the element's own coordinate system. Positions get the full transform while dimensions, being sizes, only get
+// the linear part.
+func transformBinding(property, expression string, parsed binding.Expression, m svg.Matrix) string {
+	if m.IsIdentity() || !isTransformedBinding(property, parsed) {
+		return expression
+	}
+
+	if property == "dimensions" || property == "subDimensions" {
+		m.E, m.F = 0, 0
+	}
+
+	return vec2ValueExp.ReplaceAllStringFunc(expression, func(match string) string {
+		parts := vec2ValueExp.FindStringSubmatch(match)
+		x, errX := strconv.ParseFloat(parts[2], 64)
+		y, errY := strconv.ParseFloat(parts[3], 64)
+		if errX != nil || errY != nil {
+			return match
+		}
+
+		p := m.Apply(svg.Point{X: x, Y: y})
+		return fmt.Sprintf("%s{(%s,%s)}", parts[1], formatNumber(p.X), formatNumber(p.Y))
+	})
+}
+
+// formatNumber formats the number with at most three decimals, without trailing zeros.
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(layout.RoundToNearest(f, 3), 'f', -1, 64)
+}
+
+// ReadVariables reads CSS custom properties, either as plain declarations or as CSS rules such as ":root { ... }".
+func ReadVariables(r io.Reader) (variables map[string]string, err error) {
+	var b []byte
+	if b, err = io.ReadAll(r); err != nil {
+		return
+	}
+
+	css := string(b)
+	// Plain declarations are treated as a rule for the root element
+	if !strings.Contains(css, "{") {
+		css = ":root {" + css + "}"
+	}
+
+	var sheet svg.StyleSheet
+	if sheet, _, err = svg.ParseStyleSheet(css); err != nil {
+		return
+	}
+
+	variables = make(map[string]string)
+	for _, rule := range sheet {
+		for _, d := range rule.Declarations {
+			if strings.HasPrefix(d.Property, "--") {
+				variables[d.Property] = d.Value
+			} else {
+				fmt.Printf("Warning: '%s' is not a custom property and is ignored\n", d.Property)
+			}
+		}
+	}
+
+	return
 }
 
 func ReadFileAsSvg(file *os.File) (image *svg.Svg, err error) {
@@ -2,16 +2,18 @@ package convert
 
 import (
 	"encoding/json"
+	"encoding/xml"
 	"os"
 	"strings"
 	"testing"
 
+	"github.com/PerMalmberg/du-render/svg2layout/layout"
 	"github.com/PerMalmberg/du-render/svg2layout/svg"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestOpenFiles(t *testing.T) {
-	c := NewConverter("./test_out", "./a", "./b").(*converter)
+	c := NewConverter("./test_out", Options{}, "./a", "./b").(*converter)
 	output, input, err := c.openFiles()
 	assert.Error(t, err)
 	assert.NotNil(t, output)
@@ -44,7 +46,7 @@ func TestReadFileAsSvg(t *testing.T) {
 			if text, ok := s.Value.(svg.Text); ok {
 				textDescFound = textDescFound || strings.Contains(text.Description.Text, "binding goes here for text")
 			} else if rect, ok := s.Value.(svg.Rect); ok {
-				rectDescFound = rectDescFound || strings.Contains(rect.Description.Text, "pos1:$vec2(path{gauge/fuel:value}:init{(987,603)}:interval{0.1}:percent{(987,10)})")
+				rectDescFound = rectDescFound || strings.Contains(rect.Description.Text, "pos1:$vec2(path{gauge/fuel:value}:init{(1035.979,581.661)}:interval{0.1}:percent{(1035.979,-11.339)})")
 				commonClassFound = commonClassFound || rect.Class == "common"
 			} else if circle, ok := s.Value.(svg.Circle); ok {
 				circleDescFound = circleDescFound || strings.Contains(circle.Description.Text, "$vec2(path{circle_pos:value}:init{(161,83)}:interval{0.1}")
@@ -67,7 +69,7 @@ func TestConvertPage(t *testing.T) {
 	image, err := ReadFileAsSvg(f)
 	assert.NoError(t, err)
 
-	c := NewConverter("").(*converter)
+	c := NewConverter("", Options{}).(*converter)
 	err = c.translateSvgToPage("pageName", image)
 	page := c.result.Pages["pageName"]
 	assert.NoError(t, err)
@@ -89,7 +91,7 @@ func TestConvertPage(t *testing.T) {
 
 }
 
-func TestCreateFonts(t *testing.T) {
+func TestConvertFonts(t *testing.T) {
 	f, err := os.Open("../test_data/desc.svg")
 	assert.NoError(t, err)
 	defer func() {
@@ -99,14 +101,21 @@ func TestCreateFonts(t *testing.T) {
 	image, err := ReadFileAsSvg(f)
 	assert.NoError(t, err)
 
-	c := NewConverter("").(*converter)
-	assert.NoError(t, c.createFonts(image))
-	used := c.fonts.GetUsedFonts()
-	assert.EqualValues(t, 1, len(used))
-	usedFont, ok := used["Montserrat-12"]
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("desc", image))
+	assert.NoError(t, c.applyFontBudget())
+	assert.EqualValues(t, 1, len(c.result.Fonts))
+	usedFont, ok := c.result.Fonts["Montserrat-12"]
 	assert.True(t, ok)
 	assert.Equal(t, "Montserrat", usedFont.Font)
 	assert.Equal(t, 12, usedFont.Size)
+
+	// Every font referenced by a component is part of the layout
+	for _, comp := range c.result.Pages["desc"].Components {
+		if comp.Font != nil {
+			assert.Contains(t, c.result.Fonts, *comp.Font)
+		}
+	}
 }
 
 func TestSyleMerging(t *testing.T) {
@@ -117,3 +126,891 @@ func TestSyleMerging(t *testing.T) {
 	}()
 
 }
+
+func TestConvertLines(t *testing.T) {
+	f, err := os.Open("../test_data/lines.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("lines", image))
+	page := c.result.Pages["lines"]
+	assert.Equal(t, 5, len(page.Components))
+
+	for _, comp := range page.Components {
+		assert.Equal(t, "line", comp.Type)
+		assert.NotNil(t, c.result.Styles[*comp.Style].Stroke)
+	}
+
+	assert.Equal(t, "(10.000,20.000)", page.Components[0].Pos1)
+	assert.Equal(t, "(110.000,20.000)", *page.Components[0].Pos2)
+
+	// One line per polyline segment, each with the bindings of the polyline
+	assert.Equal(t, "(60.000,100.000)", *page.Components[1].Pos2)
+	assert.Equal(t, "(60.000,100.000)", page.Components[2].Pos1)
+	assert.Equal(t, "(110.000,50.000)", *page.Components[2].Pos2)
+	assert.Contains(t, page.Components[2].Bindings, "style")
+
+	assert.Equal(t, "(200.000,300.000)", page.Components[3].Pos1)
+	assert.Equal(t, "(300.000,300.000)", *page.Components[3].Pos2)
+	assert.EqualValues(t, 3, c.result.Styles[*page.Components[3].Style].Stroke.Distance)
+
+	// The stroke width defaults to 1
+	assert.EqualValues(t, 1, c.result.Styles[*page.Components[4].Style].Stroke.Distance)
+}
+
+func TestConvertTransforms(t *testing.T) {
+	f, err := os.Open("../test_data/transform.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("transform", image))
+	page := c.result.Pages["transform"]
+	assert.Equal(t, 3, len(page.Components))
+
+	rect := page.Components[0]
+	assert.Equal(t, "(120.000,90.000)", rect.Pos1)
+	assert.Equal(t, "(180.000,170.000)", *rect.Pos2)
+	assert.Equal(t, "$vec2(path{a:b}:init{(120,90)}:percent{(100,50)})", rect.Bindings["pos1"])
+	assert.EqualValues(t, 2, c.result.Styles[*rect.Style].Stroke.Distance)
+
+	circle := page.Components[1]
+	assert.Equal(t, "(110.000,60.000)", circle.Pos1)
+	assert.EqualValues(t, 15, *circle.Radius)
+
+	line := page.Components[2]
+	assert.Equal(t, "(100.000,50.000)", line.Pos1)
+	assert.Equal(t, "(100.000,60.000)", *line.Pos2)
+}
+
+func TestConvertTransformedBindings(t *testing.T) {
+	f, err := os.Open("../test_data/bindingtransform.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("bindings", image))
+	page := c.result.Pages["bindings"]
+	assert.Equal(t, 2, len(page.Components))
+
+	// Positions get the full transform, multipliers are left as is
+	rect := page.Components[0]
+	assert.Equal(t, "$vec2(path{a:b}:init{(120,90)}:percent{(180,90)})", rect.Bindings["pos1"])
+	assert.Equal(t, "$vec2(path{a:c}:init{(2,3)}:op{mul})", rect.Bindings["pos2"])
+
+	// Dimensions are only scaled, sub is in image coordinates
+	img := page.Components[1]
+	assert.Equal(t, "$vec2(path{a:d}:init{(20,40)}:percent{(60,40)})", img.Bindings["dimensions"])
+	assert.Equal(t, "$vec2(path{a:e}:init{(1,2)})", img.Bindings["sub"])
+	assert.Equal(t, "$vec2(path{a:f}:init{(6,8)})", img.Bindings["subDimensions"])
+}
+
+func TestConvertNestedGroups(t *testing.T) {
+	f, err := os.Open("../test_data/groups.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("groups", image))
+	page := c.result.Pages["groups"]
+	assert.Equal(t, 3, len(page.Components))
+
+	// Style, class and transform inherited from the groups
+	inherits := page.Components[0]
+	assert.Equal(t, 1, inherits.Layer)
+	assert.Equal(t, "(110.000,10.000)", inherits.Pos1)
+	style := c.result.Styles[*inherits.Style]
+	assert.EqualValues(t, 1, style.Fill.Red)
+	assert.EqualValues(t, 1, style.Stroke.Color.Green)
+	assert.EqualValues(t, 4, style.Stroke.Distance)
+
+	// Overridden by the inner group and the element itself
+	overrides := page.Components[1]
+	assert.Equal(t, 1, overrides.Layer)
+	assert.Equal(t, "(110.000,10.000)", overrides.Pos1)
+	assert.Equal(t, "(130.000,30.000)", *overrides.Pos2)
+	style = c.result.Styles[*overrides.Style]
+	assert.EqualValues(t, 0, style.Fill.Red)
+	assert.EqualValues(t, 1, style.Fill.Blue)
+	assert.EqualValues(t, 2, style.Stroke.Distance)
+
+	assert.Equal(t, 2, page.Components[2].Layer)
+}
+
+func TestConvertRotation(t *testing.T) {
+	f, err := os.Open("../test_data/rotation.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("rotation", image))
+	page := c.result.Pages["rotation"]
+	assert.Equal(t, 4, len(page.Components))
+
+	// Rotated around its center, which is also the center of the rotation
+	rect := page.Components[0]
+	assert.Equal(t, "(100.000,100.000)", rect.Pos1)
+	assert.Equal(t, "(140.000,120.000)", *rect.Pos2)
+	assert.EqualValues(t, -30, *c.result.Styles[*rect.Style].Rotation)
+
+	// Rotated 90 degrees and scaled by 2, center (0+20,0+10) => (200-20,100+40)
+	rect = page.Components[1]
+	assert.Equal(t, "(140.000,120.000)", rect.Pos1)
+	assert.Equal(t, "(220.000,160.000)", *rect.Pos2)
+	assert.EqualValues(t, -90, *c.result.Styles[*rect.Style].Rotation)
+
+	text := page.Components[2]
+	assert.Equal(t, "(50.000,60.000)", text.Pos1)
+	assert.EqualValues(t, 90, *c.result.Styles[*text.Style].Rotation)
+
+	// Mirrored texts are not turned upside down
+	text = page.Components[3]
+	assert.Equal(t, "(-50.000,60.000)", text.Pos1)
+	assert.Nil(t, c.result.Styles[*text.Style].Rotation)
+
+	// Unrotated components don't carry a rotation
+	c = NewConverter("", Options{}).(*converter)
+	f2, err := os.Open("../test_data/transform.svg")
+	assert.NoError(t, err)
+	defer f2.Close()
+	image, err = ReadFileAsSvg(f2)
+	assert.NoError(t, err)
+	assert.NoError(t, c.translateSvgToPage("transform", image))
+	for _, comp := range c.result.Pages["transform"].Components {
+		if comp.Type != "line" {
+			assert.Nil(t, c.result.Styles[*comp.Style].Rotation)
+		}
+	}
+}
+
+func TestConvertImages(t *testing.T) {
+	f, err := os.Open("../test_data/image.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	// Unmapped local images can't be loaded by the screen
+	c := NewConverter("", Options{}).(*converter)
+	assert.Error(t, c.translateSvgToPage("images", image))
+
+	c = NewConverter("", Options{
+		ImageUrls: map[string]string{"logo.png": "https://assets.prod.novaquark.com/logo.png"},
+	}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("images", image))
+	page := c.result.Pages["images"]
+	assert.Equal(t, 3, len(page.Components))
+
+	mapped := page.Components[0]
+	assert.Equal(t, "image", mapped.Type)
+	assert.Equal(t, "https://assets.prod.novaquark.com/logo.png", *mapped.Url)
+	assert.Equal(t, "(10.000,20.000)", mapped.Pos1)
+	assert.Equal(t, "(100.000,50.000)", *mapped.Dimensions)
+	assert.Nil(t, mapped.Sub)
+
+	assert.Equal(t, "https://assets.prod.novaquark.com/web.png", *page.Components[1].Url)
+
+	described := page.Components[2]
+	assert.Equal(t, "https://assets.prod.novaquark.com/described.png", *described.Url)
+	assert.Equal(t, "(5.000,5.000)", described.Pos1)
+	assert.Equal(t, "(10.000,20.000)", *described.Dimensions)
+	assert.Equal(t, "(1,2)", *described.Sub)
+	assert.Equal(t, "(3,4)", *described.SubDimensions)
+
+	j, err := json.Marshal(page)
+	assert.NoError(t, err)
+	assert.Contains(t, string(j), `"subDimensions":"(3,4)"`)
+}
+
+func TestConvertRoundedShapes(t *testing.T) {
+	f, err := os.Open("../test_data/rounded.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("rounded", image))
+	page := c.result.Pages["rounded"]
+	assert.Equal(t, 6, len(page.Components))
+
+	assert.EqualValues(t, 5, *page.Components[0].CornerRadius)
+	assert.EqualValues(t, 5, *page.Components[1].CornerRadius)
+	assert.EqualValues(t, 5, *page.Components[2].CornerRadius)
+	assert.EqualValues(t, 10, *page.Components[3].CornerRadius)
+
+	round := page.Components[4]
+	assert.Equal(t, "circle", round.Type)
+	assert.Equal(t, "(50.000,60.000)", round.Pos1)
+	assert.InDelta(t, 10.025, *round.Radius, 1e-9)
+
+	oval := page.Components[5]
+	assert.Equal(t, "box", oval.Type)
+	assert.Equal(t, "(30.000,50.000)", oval.Pos1)
+	assert.Equal(t, "(70.000,70.000)", *oval.Pos2)
+	assert.EqualValues(t, 10, *oval.CornerRadius)
+}
+
+func TestPathEffectCornerRadius(t *testing.T) {
+	f, err := os.Open("../test_data/desc.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("pageName", image))
+	page := c.result.Pages["pageName"]
+	gauge := page.Components[len(page.Components)-1]
+	assert.EqualValues(t, 11, *gauge.CornerRadius)
+	assert.Nil(t, page.Components[0].CornerRadius)
+}
+
+func TestConvertPathHoles(t *testing.T) {
+	f, err := os.Open("../test_data/hole.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("hole", image))
+	comps := c.result.Pages["hole"].Components
+
+	// The hole isn't filled, but both outlines are drawn. Separate sub paths are all filled.
+	types := make([]string, len(comps))
+	for i, comp := range comps {
+		types[i] = comp.Type
+	}
+	assert.Equal(t, []string{"quad", "line", "line", "line", "line", "line", "line", "line", "line", "quad", "quad"}, types)
+}
+
+func TestConvertPaths(t *testing.T) {
+	f, err := os.Open("../test_data/paths.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{Tolerance: 0.1}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("paths", image))
+	page := c.result.Pages["paths"]
+
+	count := func(compType string) (n int) {
+		for _, comp := range page.Components {
+			if comp.Type == compType {
+				n++
+			}
+		}
+		return
+	}
+
+	// Arrow: two triangles, square: one quad with four outline lines, curve: a single bezier
+	assert.Equal(t, 2, count("triangle"))
+	assert.Equal(t, 1, count("quad"))
+	assert.Equal(t, 4, count("line"))
+	assert.Equal(t, 1, count("bezier"))
+
+	for _, comp := range page.Components {
+		style := c.result.Styles[*comp.Style]
+		switch comp.Type {
+		case "triangle":
+			assert.NotNil(t, comp.Pos3)
+			assert.Nil(t, comp.Pos4)
+			assert.Nil(t, style.Stroke)
+		case "quad":
+			assert.NotNil(t, comp.Pos4)
+			assert.Nil(t, style.Stroke)
+			assert.EqualValues(t, 1, style.Fill.Green)
+			assert.Contains(t, []string{comp.Pos1, *comp.Pos2, *comp.Pos3, *comp.Pos4}, "(110.000,100.000)")
+			assert.Contains(t, comp.Bindings, "visible")
+		case "line":
+			assert.NotNil(t, style.Stroke)
+		}
+	}
+}
+
+func TestConvertBeziers(t *testing.T) {
+	f, err := os.Open("../test_data/beziers.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{Tolerance: 0.1}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("beziers", image))
+	comps := c.result.Pages["beziers"].Components
+
+	// Quadratics: two beziers, the second with a reflected control point
+	assert.Equal(t, "bezier", comps[0].Type)
+	assert.Equal(t, "(110.000,120.000)", comps[0].Pos1)
+	assert.Equal(t, "(160.000,70.000)", *comps[0].Pos2)
+	assert.Equal(t, "(210.000,120.000)", *comps[0].Pos3)
+	assert.Equal(t, "bezier", comps[1].Type)
+	assert.Equal(t, "(260.000,170.000)", *comps[1].Pos2)
+	assert.Equal(t, "(310.000,120.000)", *comps[1].Pos3)
+
+	style := c.result.Styles[*comps[0].Style]
+	assert.Nil(t, style.Fill)
+	assert.EqualValues(t, 2, style.Stroke.Distance)
+	assert.EqualValues(t, 1, style.Stroke.Color.Red)
+
+	// Unindexed bindings apply to all components, indexed ones only to the one at the index
+	assert.Contains(t, comps[0].Bindings, "visible")
+	assert.Contains(t, comps[1].Bindings, "visible")
+	assert.NotContains(t, comps[0].Bindings, "pos2")
+	assert.Equal(t, "$vec2(path{a:b}:init{(260,170)})", comps[1].Bindings["pos2"])
+
+	// Mixed: a line followed by the cubic, which is exactly represented by a quadratic
+	assert.Equal(t, "line", comps[2].Type)
+	assert.Equal(t, "bezier", comps[3].Type)
+	assert.Equal(t, "(0.000,300.000)", comps[2].Pos1)
+	assert.Equal(t, "(65.000,285.000)", *comps[3].Pos2)
+
+	// The wave needs too many quadratics so it is made of lines, as is the outline of the filled path.
+	for _, comp := range comps[4:] {
+		assert.NotEqual(t, "bezier", comp.Type)
+	}
+}
+
+func TestConvertHidden(t *testing.T) {
+	f, err := os.Open("../test_data/hidden.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	// Hidden elements are skipped by default
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("hidden", image))
+	comps := c.result.Pages["hidden"].Components
+
+	assert.Equal(t, 2, len(comps))
+	assert.Equal(t, "(0.000,0.000)", comps[0].Pos1)
+	assert.Equal(t, "(60.000,0.000)", comps[1].Pos1)
+	for _, comp := range comps {
+		assert.True(t, comp.Visible)
+	}
+
+	// When kept, they are invisible; the scaffolding layer is still excluded and doesn't use a layer number.
+	c = NewConverter("", Options{KeepHidden: true}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("hidden", image))
+	comps = c.result.Pages["hidden"].Components
+
+	assert.Equal(t, 6, len(comps))
+	visible := []bool{true, false, false, true, false, false}
+	for i, comp := range comps {
+		assert.Equal(t, visible[i], comp.Visible, i)
+	}
+	assert.Equal(t, "circle", comps[4].Type)
+	assert.Equal(t, 2, comps[5].Layer)
+	assert.Equal(t, "(0.000,200.000)", comps[5].Pos1)
+}
+
+func TestConvertOpacity(t *testing.T) {
+	f, err := os.Open("../test_data/opacity.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("opacity", image))
+	comps := c.result.Pages["opacity"].Components
+
+	// Opacity multiplies down the tree and into both fill and stroke
+	style := c.result.Styles[*comps[0].Style]
+	assert.EqualValues(t, 0.2, style.Fill.Alpha)
+	assert.EqualValues(t, 0.4, style.Stroke.Color.Alpha)
+
+	assert.EqualValues(t, 0.25, c.result.Styles[*comps[1].Style].Fill.Alpha)
+
+	// Equal resulting colors are merged into the same style
+	c.replaceStyles()
+	assert.Equal(t, *comps[1].Style, *comps[2].Style)
+}
+
+func TestConvertHdrColors(t *testing.T) {
+	f, err := os.Open("../test_data/hdr.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("hdr", image))
+	comps := c.result.Pages["hdr"].Components
+
+	// Intensity from a class and inherited from the group
+	assert.EqualValues(t, 4, c.result.Styles[*comps[0].Style].Fill.Green)
+	assert.EqualValues(t, 1.004, c.result.Styles[*comps[1].Style].Stroke.Color.Red)
+}
+
+func TestConvertStyleSheet(t *testing.T) {
+	f, err := os.Open("../test_data/css.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("css", image))
+	comps := c.result.Pages["css"].Components
+
+	fills := []layout.Color{
+		{Red: 1, Green: 1, Blue: 1, Alpha: 1},
+		{Red: 1, Green: 1, Blue: 0, Alpha: 1},
+		{Red: 1, Green: 1, Blue: 0, Alpha: 1},
+		{Red: 1, Green: 0.502, Blue: 0, Alpha: 1},
+		{Red: 0, Green: 0, Blue: 1, Alpha: 1},
+		{Red: 0, Green: 0, Blue: 0, Alpha: 1},
+	}
+
+	for i, comp := range comps {
+		assert.Equal(t, fills[i], *c.result.Styles[*comp.Style].Fill, i)
+	}
+
+	assert.NotNil(t, c.result.Styles[*comps[2].Style].Stroke)
+
+	// Rules for single classes keep their common style names, grouped selectors included
+	assert.Contains(t, c.commonStyles, "css-warning")
+	assert.Contains(t, c.commonStyles, "css-error")
+	assert.NotContains(t, c.commonStyles, "css-blinking")
+	assert.NotNil(t, c.commonStyles["css-error"].Stroke)
+
+	c.replaceStyles()
+	assert.Equal(t, "css-warning", *comps[1].Style)
+}
+
+func TestConvertTextClass(t *testing.T) {
+	f, err := os.Open("../test_data/textclass.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("text", image))
+	comps := c.result.Pages["text"].Components
+	assert.Equal(t, 4, len(comps))
+
+	// Texts carry an alignment, so they map to a variant of the common style per alignment
+	c.replaceStyles()
+	assert.Equal(t, "text-label", *comps[0].Style)
+	assert.Equal(t, "text-label-text-h0-v3", *comps[1].Style)
+	assert.Equal(t, "text-label-text-h0-v3", *comps[2].Style)
+	assert.Equal(t, "text-label-text-h1-v3", *comps[3].Style)
+	assert.Equal(t, "h1,v3", *c.result.Styles[*comps[3].Style].Align)
+	assert.Nil(t, c.result.Styles["text-label"].Align)
+	assert.Equal(t, 3, len(c.result.Styles))
+}
+
+func TestConvertPresentationAttributes(t *testing.T) {
+	f, err := os.Open("../test_data/presentation.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("attrs", image))
+	comps := c.result.Pages["attrs"].Components
+	assert.Equal(t, 4, len(comps))
+
+	text := c.result.Styles[*comps[0].Style]
+	assert.Equal(t, "h1,v3", *text.Align)
+	assert.Equal(t, layout.Color{Red: 1, Green: 0, Blue: 0, Alpha: 1}, *text.Fill)
+	assert.Equal(t, "RobotoMono-20", *comps[0].Font)
+
+	// Fill is inherited from the group
+	rect := c.result.Styles[*comps[1].Style]
+	assert.Equal(t, layout.Color{Red: 0, Green: 0, Blue: 1, Alpha: 1}, *rect.Fill)
+	assert.Equal(t, layout.Color{Red: 0, Green: 0.502, Blue: 0, Alpha: 1}, rect.Stroke.Color)
+	assert.EqualValues(t, 2, rect.Stroke.Distance)
+
+	// The style attribute and style sheet rules take precedence
+	assert.Equal(t, layout.Color{Red: 1, Green: 1, Blue: 1, Alpha: 1}, *c.result.Styles[*comps[2].Style].Fill)
+	assert.Equal(t, layout.Color{Red: 0, Green: 1, Blue: 0, Alpha: 1}, *c.result.Styles[*comps[3].Style].Fill)
+}
+
+func TestConvertCustomProperties(t *testing.T) {
+	f, err := os.Open("../test_data/theme.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	fill := func(c *converter, comp layout.Component) layout.Color {
+		return *c.result.Styles[*comp.Style].Fill
+	}
+
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("theme", image))
+	comps := c.result.Pages["theme"].Components
+
+	assert.Equal(t, layout.Color{Red: 0, Green: 0, Blue: 0, Alpha: 1}, fill(c, comps[0]))
+	assert.EqualValues(t, 1, c.result.Styles[*comps[0].Style].Stroke.Color.Red)
+	assert.Equal(t, layout.Color{Red: 0, Green: 1, Blue: 0, Alpha: 1}, fill(c, comps[1]))
+	assert.Equal(t, layout.Color{Red: 1, Green: 1, Blue: 0, Alpha: 1}, fill(c, comps[2]))
+	assert.Equal(t, layout.Color{Red: 0, Green: 0, Blue: 0, Alpha: 1}, *c.commonStyles["theme-panel"].Fill)
+
+	// Overridden variables apply everywhere, including common styles
+	variables, err := ReadVariables(strings.NewReader("/* Blue theme */ --background: #0000ff; accent: #ffffff"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"--background": "#0000ff"}, variables)
+	variables["accent"] = "#ffffff"
+
+	c = NewConverter("", Options{Variables: variables}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("theme", image))
+	comps = c.result.Pages["theme"].Components
+
+	assert.Equal(t, layout.Color{Red: 0, Green: 0, Blue: 1, Alpha: 1}, fill(c, comps[0]))
+	assert.Equal(t, layout.Color{Red: 1, Green: 1, Blue: 1, Alpha: 1}, fill(c, comps[1]))
+	assert.Equal(t, layout.Color{Red: 0, Green: 0, Blue: 1, Alpha: 1}, *c.commonStyles["theme-panel"].Fill)
+}
+
+func TestConvertShadows(t *testing.T) {
+	f, err := os.Open("../test_data/shadow.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("shadow", image))
+	comps := c.result.Pages["shadow"].Components
+	shadow := func(i int) *layout.Shadow {
+		return c.result.Styles[*comps[i].Style].Shadow
+	}
+
+	// feDropShadow
+	if assert.NotNil(t, shadow(0)) {
+		assert.Equal(t, layout.Color{Red: 1, Green: 0, Blue: 0, Alpha: 0.5}, shadow(0).Color)
+		assert.EqualValues(t, 6, shadow(0).Distance)
+	}
+
+	// Inkscape drop shadow, with averaged deviation scaled by the transform
+	if assert.NotNil(t, shadow(1)) {
+		assert.Equal(t, layout.Color{Red: 0, Green: 0, Blue: 0, Alpha: 0.498}, shadow(1).Color)
+		assert.EqualValues(t, 12, shadow(1).Distance)
+	}
+
+	// Unsupported filter
+	assert.Nil(t, shadow(2))
+
+	// Filter on a group applies to its children, which fade with the group
+	if assert.NotNil(t, shadow(3)) {
+		assert.EqualValues(t, 0.25, shadow(3).Color.Alpha)
+	}
+}
+
+func TestConvertTextAlign(t *testing.T) {
+	f, err := os.Open("../test_data/align.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("align", image))
+	comps := c.result.Pages["align"].Components
+
+	assert.Equal(t, "h1,v2", *c.result.Styles[*comps[0].Style].Align)
+	assert.Equal(t, "h2,v3", *c.result.Styles[*comps[1].Style].Align)
+	assert.Nil(t, c.result.Styles[*comps[2].Style].Align)
+}
+
+func TestConvertMultilineText(t *testing.T) {
+	f, err := os.Open("../test_data/multiline.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("multiline", image))
+	comps := c.result.Pages["multiline"].Components
+	assert.Equal(t, 5, len(comps))
+
+	// One component per line, with bindings targeting the second line
+	assert.Equal(t, "Status", *comps[0].Text)
+	assert.Equal(t, "(100.000,100.000)", comps[0].Pos1)
+	assert.Empty(t, comps[0].Bindings)
+	assert.Equal(t, "Idle", *comps[1].Text)
+	assert.Equal(t, "(100.000,112.000)", comps[1].Pos1)
+	assert.Equal(t, "$str(path{status:value}:init{Idle})", comps[1].Bindings["text"])
+
+	// Runs with their own style, positioned after the preceding text or by offsets
+	assert.Equal(t, "Fuel: ", *comps[2].Text)
+	assert.Equal(t, "Play-10", *comps[2].Font)
+	assert.Equal(t, "low", *comps[3].Text)
+	assert.Equal(t, "Play-20", *comps[3].Font)
+	assert.Equal(t, "(336.000,102.000)", comps[3].Pos1)
+	assert.Equal(t, layout.Color{Red: 1, Green: 0, Blue: 0, Alpha: 1}, *c.result.Styles[*comps[3].Style].Fill)
+	assert.Equal(t, "Refill", *comps[4].Text)
+	assert.Equal(t, "(300.000,126.000)", comps[4].Pos1)
+
+	assert.NoError(t, c.applyFontBudget())
+	assert.Equal(t, 2, len(c.result.Fonts))
+	assert.Contains(t, c.result.Fonts, "Play-10")
+	assert.Contains(t, c.result.Fonts, "Play-20")
+}
+
+func TestConvertPlainText(t *testing.T) {
+	f, err := os.Open("../test_data/plaintext.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("plaintext", image))
+	comps := c.result.Pages["plaintext"].Components
+
+	texts := []string{}
+	for _, comp := range comps {
+		texts = append(texts, *comp.Text)
+	}
+
+	assert.Equal(t, []string{"Hello", "Hello world", "  two  spaces", "Total: ", "42", " units", "a  b", "First", " Second "}, texts)
+	assert.Equal(t, "(10.000,20.000)", comps[0].Pos1)
+	assert.Equal(t, "Play-10", *comps[0].Font)
+	assert.Equal(t, "$num(path{fuel:total}:init{0})", comps[4].Bindings["text"])
+	assert.Equal(t, "(10.000,132.000)", comps[8].Pos1)
+}
+
+func TestConvertFontBudget(t *testing.T) {
+	f, err := os.Open("../test_data/fonts.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("fonts", image))
+	assert.ErrorContains(t, c.applyFontBudget(), "10 fonts are used, at most 8 are supported")
+
+	c = NewConverter("", Options{MergeFonts: true}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("fonts", image))
+	assert.NoError(t, c.applyFontBudget())
+	assert.Equal(t, MaxFonts, len(c.result.Fonts))
+	assert.NotContains(t, c.result.Fonts, "Play-10")
+	assert.NotContains(t, c.result.Fonts, "Montserrat-12")
+
+	comps := c.result.Pages["fonts"].Components
+	assert.Equal(t, "Play-11", *comps[0].Font)
+	assert.Equal(t, "Play-20", *comps[3].Font)
+	assert.Equal(t, "Montserrat-13", *comps[7].Font)
+}
+
+func TestConvertDataAttributes(t *testing.T) {
+	f, err := os.Open("../test_data/data.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("data", image))
+	comps := c.result.Pages["data"].Components
+	assert.Equal(t, 4, len(comps))
+
+	// Attributes are merged with the description
+	assert.Equal(t, "$vec2(path{gauge:value}:init{(110,70)})", comps[0].Bindings["pos2"])
+	assert.Equal(t, "$str(path{gauge:style}:init{default})", comps[0].Bindings["style"])
+
+	// Indexed by a numeric suffix
+	assert.Empty(t, comps[1].Bindings)
+	assert.Equal(t, "$str(path{status:value}:init{Idle})", comps[2].Bindings["text"])
+
+	// Options
+	assert.Equal(t, "https://assets.prod.novaquark.com/logo.png", *comps[3].Url)
+	assert.Equal(t, "(1,2)", *comps[3].Sub)
+	assert.Equal(t, "(3,4)", *comps[3].SubDimensions)
+}
+
+func TestElementAnnotations(t *testing.T) {
+	styled := &svg.StyledShape{
+		Id:          "gauge",
+		Description: svg.Description{Text: "pos1:$vec2(path{a:b}:init{(1,2)})\ntext[2]:$str(path{a:c}:init{x})"},
+		Attrs: []xml.Attr{
+			{Name: xml.Name{Local: "data-du-pos2"}, Value: " $vec2(path{a:d}:init{(3,4)}) "},
+			{Name: xml.Name{Local: "data-du-text-1"}, Value: "$str(path{a:e}:init{y})"},
+		},
+	}
+
+	annotations, err := elementAnnotations(styled)
+	assert.NoError(t, err)
+	assert.Contains(t, annotations, "pos2:$vec2(path{a:d}:init{(3,4)})")
+	assert.Contains(t, annotations, "text[1]:$str(path{a:e}:init{y})")
+
+	// The same entry in both the description and an attribute is a conflict
+	styled.Attrs = append(styled.Attrs, xml.Attr{Name: xml.Name{Local: "data-du-text-2"}, Value: "$str(path{a:f}:init{z})"})
+	_, err = elementAnnotations(styled)
+	assert.ErrorContains(t, err, "'text[2]' of element 'gauge' is defined both by data-du-text-2 and its description")
+
+	styled.Attrs = []xml.Attr{{Name: xml.Name{Local: "data-du-pos1"}, Value: "$vec2(path{a:g}:init{(5,6)})"}}
+	_, err = elementAnnotations(styled)
+	assert.ErrorContains(t, err, "'pos1' of element 'gauge' is defined both by data-du-pos1 and its description")
+}
+
+func TestConvertBindings(t *testing.T) {
+	f, err := os.Open("../test_data/bindings.svg")
+	assert.NoError(t, err)
+	defer func() {
+		f.Close()
+	}()
+
+	image, err := ReadFileAsSvg(f)
+	assert.NoError(t, err)
+
+	c := NewConverter("", Options{}).(*converter)
+	assert.NoError(t, c.translateSvgToPage("bindings", image))
+	comps := c.result.Pages["bindings"].Components
+
+	assert.Equal(t, "$bool(path{panel:shown}:init{true})", comps[0].Bindings["visible"])
+	assert.Equal(t, "$bool(path{panel:hit}:init{true})", comps[0].Bindings["hitable"])
+
+	// Unindexed bindings only apply to the components supporting them, here the quad but not the outline
+	quads, lines := 0, 0
+	for _, comp := range comps[1:] {
+		_, bound := comp.Bindings["pos4"]
+		if comp.Type == "quad" {
+			quads++
+			assert.True(t, bound)
+		} else {
+			lines++
+			assert.False(t, bound)
+		}
+	}
+	assert.Equal(t, 1, quads)
+	assert.Equal(t, 4, lines)
+
+	// Properties that can't be bound are errors naming the element
+	ctx := elementContext{pageName: "bindings", transform: svg.Identity(), opacity: 1}
+	tests := []struct {
+		desc string
+		err  string
+	}{
+		{"radius:$num(path{a:r}:init{1})", "'radius' of element 'panel' can't be bound on a box"},
+		{"pos3:$vec2(path{a:p}:init{(1,2)})", "'pos3' of element 'panel' can't be bound on a box"},
+		{"pos1:$vec2(path{a:b}:init{(1,2)}:intervall{0.1})", "invalid binding of 'pos1' of element 'panel': unknown attribute 'intervall' in $vec2"},
+		{"visible:$num(path{a:b}:init{(1,2)})", "invalid binding of 'visible' of element 'panel': init value '(1,2)' of $num is not a number"},
+		{"pos1 : $vec2 (path{a:b}:init{(1,2)})", "invalid binding 'pos1 : $vec2 (path{a:b}:init{(1,2)})' of element 'panel'"},
+	}
+
+	for _, test := range tests {
+		rect := image.Layer[0].Shape[0].Value.(svg.Rect)
+		rect.Description.Text = test.desc
+		_, err = c.translateRect(ctx, image, rect)
+		assert.ErrorContains(t, err, test.err)
+	}
+
+	path := image.Layer[0].Shape[1].Value.(svg.Path)
+	path.Description.Text = "text:$str(path{a:t}:init{x})"
+	_, err = c.translatePath(ctx, path)
+	assert.ErrorContains(t, err, "'text' of element 'arrow' can't be bound on any of its components")
+
+	path.Description.Text = "pos4[2]:$vec2(path{a:p}:init{(1,2)})"
+	_, err = c.translatePath(ctx, path)
+	assert.ErrorContains(t, err, "'pos4' of element 'arrow' can't be bound on a line")
+
+	// Rotated boxes are placed around their center, which bound positions can't follow
+	rect := svg.Rect{
+		ShapeArea:        svg.ShapeArea{Width: 10, Height: 10},
+		TransformedShape: svg.TransformedShape{Transform: "rotate(90)"},
+		StyledShape:      svg.StyledShape{Id: "rotated"},
+	}
+	rect.Description.Text = "pos1:$vec2(path{a:b}:init{(0,0)})\npos2:$vec2(path{a:c}:init{(10,10)})"
+	_, err = c.translateRect(ctx, image, rect)
+	assert.ErrorContains(t, err, "'pos1' of element 'rotated' can't be bound with $vec2 as the element is rotated")
+
+	rect.Description.Text = "pos2:$vec2(path{a:c}:init{(1,1)}:op{mul})\nvisible:$bool(path{a:v}:init{true})"
+	comps, err = c.translateRect(ctx, image, rect)
+	assert.NoError(t, err)
+	assert.Equal(t, "(-10.000,0.000)", comps[0].Pos1)
+	assert.Equal(t, "$vec2(path{a:c}:init{(1,1)}:op{mul})", comps[0].Bindings["pos2"])
+}
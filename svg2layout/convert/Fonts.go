@@ -1,160 +1,304 @@
 package convert
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"math"
-	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/PerMalmberg/du-render/svg2layout/layout"
+	"github.com/PerMalmberg/du-render/svg2layout/svg"
 )
 
+// FontVariant tells which variants of a font are available in RenderScript
 type FontVariant struct {
-	Bold    bool
-	Light   bool
-	Regular bool
+	Bold    bool `json:"bold"`
+	Light   bool `json:"light"`
+	Regular bool `json:"regular"`
+}
+
+// FontConfig controls how the font families of texts are mapped to the fonts of RenderScript
+type FontConfig struct {
+	// Fonts adds fonts, or replaces the variants of the built-in ones, as they become available in RenderScript.
+	Fonts map[string]FontVariant `json:"fonts"`
+	// Aliases maps font families, such as "DejaVu Sans Mono" or "monospace", to one of the fonts.
+	Aliases map[string]string `json:"aliases"`
+	// DefaultFont is used for texts without a supported font family, RobotoMono when empty.
+	DefaultFont string `json:"defaultFont"`
+	// DefaultSize is the size of texts without a font size, 10 when zero.
+	DefaultSize int `json:"defaultSize"`
+	// ResetSize uses the default size, rather than the size of the text, when the default font is substituted.
+	ResetSize bool `json:"resetSize"`
 }
 
 type IFonts interface {
 	GetFont(style string) (name string, substituted bool)
-	UseFont(name string)
-	GetUsedFonts() map[string]*layout.Font
+	// Font returns the font created by GetFont
+	Font(name string) (font *layout.Font, found bool)
 }
 
 type fonts struct {
 	allowed map[string]FontVariant
-	current map[string]*layout.Font
-	used    map[string]*layout.Font
+	// aliases maps normalized family names to fonts
+	aliases     map[string]string
+	defaultFont string
+	defaultSize int
+	resetSize   bool
+	current     map[string]*layout.Font
 }
 
 var defaultFont = "RobotoMono"
 var defaultSize = 10
 
-func NewFonts() IFonts {
+// builtinFonts are the fonts available in RenderScript
+var builtinFonts = map[string]FontVariant{
+	"FiraMono":           {Bold: true, Light: false, Regular: true},
+	"Montserrat":         {Bold: true, Light: true, Regular: true},
+	"Play":               {Bold: true, Light: false, Regular: true},
+	"RefrigeratorDeluxe": {Bold: false, Light: true, Regular: true},
+	"RobotoCondensed":    {Bold: false, Light: false, Regular: true},
+	"RobotoMono":         {Bold: true, Light: false, Regular: true},
+}
+
+func NewFonts(config FontConfig) IFonts {
 	f := &fonts{
-		allowed: map[string]FontVariant{},
-		current: map[string]*layout.Font{},
-		used:    map[string]*layout.Font{},
+		allowed:     map[string]FontVariant{},
+		aliases:     map[string]string{},
+		defaultFont: config.DefaultFont,
+		defaultSize: config.DefaultSize,
+		resetSize:   config.ResetSize,
+		current:     map[string]*layout.Font{},
 	}
 
-	f.allowed["FiraMono"] = FontVariant{
-		Bold:    true,
-		Light:   false,
-		Regular: true,
+	for name, variant := range builtinFonts {
+		f.allowed[name] = variant
 	}
 
-	f.allowed["Montserrat"] = FontVariant{
-		Bold:    true,
-		Light:   true,
-		Regular: true,
+	for name, variant := range config.Fonts {
+		f.allowed[name] = variant
 	}
 
-	f.allowed["Play"] = FontVariant{
-		Bold:    true,
-		Light:   false,
-		Regular: true,
+	for family, font := range config.Aliases {
+		f.aliases[normalizeFamily(family)] = font
 	}
 
-	f.allowed["RefrigeratorDeluxe"] = FontVariant{
-		Bold:    false,
-		Light:   true,
-		Regular: true,
+	if f.defaultFont == "" {
+		f.defaultFont = defaultFont
+	} else if name, found := f.findFamily(f.defaultFont); found {
+		f.defaultFont = name
 	}
 
-	f.allowed["RobotoCondensed"] = FontVariant{
-		Bold:    false,
-		Light:   false,
-		Regular: true,
+	if f.defaultSize <= 0 {
+		f.defaultSize = defaultSize
 	}
 
-	f.allowed["RobotoMono"] = FontVariant{
-		Bold:    true,
-		Light:   false,
-		Regular: true,
+	return f
+}
+
+// ReadFontConfig reads a font configuration in JSON format, such as
+// {"fonts": {"NewFont": {"regular": true}}, "aliases": {"monospace": "FiraMono"}, "defaultFont": "Play"}
+func ReadFontConfig(r io.Reader) (config FontConfig, err error) {
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+	if err = d.Decode(&config); err != nil {
+		err = fmt.Errorf("invalid font config: %v", err)
+		return
 	}
 
-	return f
+	err = config.Validate()
+	return
+}
+
+// Validate checks that the aliases and the default font refer to available fonts
+func (c FontConfig) Validate() error {
+	f := NewFonts(c).(*fonts)
+
+	for family, font := range c.Aliases {
+		if _, found := f.findFamily(font); !found {
+			return fmt.Errorf("font alias '%s' refers to unknown font '%s'", family, font)
+		}
+	}
+
+	if _, found := f.findFamily(f.defaultFont); !found {
+		return fmt.Errorf("unknown default font '%s'", c.DefaultFont)
+	}
+
+	return nil
 }
 
-func (f *fonts) GetUsedFonts() map[string]*layout.Font {
-	return f.used
+func (f *fonts) Font(name string) (font *layout.Font, found bool) {
+	font, found = f.current[name]
+	return
 }
 
-func (f *fonts) GetFont(style string) (name string, substituted bool) {
-	// Extract font-family, font-weight and font-size
-	// Round font size to nearest integer
-	// Match against the allowed fonts
-	// Find existing font with same attributes or add new
-	// font-style:normal;font-variant:normal;font-weight:normal;font-stretch:normal;font-size:6.03182px;font-family:monospace;-inkscape-font-specification:monospace;fill:#000000;stroke:none;stroke-width:0.180955
-	fontSizeExp := regexp.MustCompile(`font-size:(\d*\.?\d*)px`)
-	fontFamilyExp := regexp.MustCompile(`font-family:(.+?)(?:;|$)`)
-	fontWeightExp := regexp.MustCompile(`font-weight:(.+?)(?:;|$)`)
-	inkscapeFontSpecExp := regexp.MustCompile(`-inkscape-font-specification:'(.+?)'`)
-
-	size := fontSizeExp.FindStringSubmatch(style)
-	family := fontFamilyExp.FindStringSubmatch(style)
-	weight := fontWeightExp.FindStringSubmatch(style)
-	fontSpec := inkscapeFontSpecExp.FindStringSubmatch(style)
-
-	bold := false
-	light := false
-
-	if fontSpec != nil {
-		bold = strings.HasSuffix(fontSpec[1], "Bold")
-		light = strings.HasSuffix(fontSpec[1], "Light")
-	}
-
-	if weight != nil {
-		if !bold {
-			bold = weight[1] == "bold"
+// fontRequest holds the font properties of a style
+type fontRequest struct {
+	// families are the font families in order of preference
+	families []string
+	bold     bool
+	light    bool
+	italic   bool
+	size     float64
+}
+
+// fontWeight classifies a font-weight value as bold or light, numeric weights of 600 and above are bold
+// and those of 300 and below are light.
+func fontWeight(weight string) (bold, light bool) {
+	switch weight {
+	case "bold", "bolder":
+		return true, false
+	case "lighter":
+		return false, true
+	}
+
+	if w, err := strconv.ParseFloat(weight, 64); err == nil {
+		return w >= 600, w <= 300
+	}
+
+	return false, false
+}
+
+// fontFamilies splits a font-family list, such as "'Roboto Mono', monospace", into its names
+func fontFamilies(value string) (families []string) {
+	for _, family := range strings.Split(value, ",") {
+		family = strings.Trim(strings.TrimSpace(family), `'"`)
+		if family != "" {
+			families = append(families, family)
 		}
+	}
+	return
+}
 
-		if !light {
-			bold = weight[1] == "light"
+// parseFontRequest extracts the font properties from inline CSS, the weight given by font-weight takes precedence
+// over the one in the Inkscape font specification, such as 'Montserrat Bold'.
+func parseFontRequest(style string, defaultSize int) (req fontRequest) {
+	decl := svg.ParseDeclarations(style)
+
+	req.size = float64(defaultSize)
+	if size, found := decl.Get("font-size"); found {
+		if fSize, err := strconv.ParseFloat(strings.TrimSuffix(size, "px"), 64); err == nil {
+			req.size = fSize
+		} else {
+			fmt.Printf("Warning: unsupported font size '%s', using %d\n", size, defaultSize)
 		}
 	}
 
-	if size != nil {
-		fSize, err := strconv.ParseFloat(size[1], 32)
-		if err != nil {
-			fSize = float64(defaultSize)
+	if family, found := decl.Get("font-family"); found {
+		req.families = fontFamilies(family)
+	}
+
+	if spec, found := decl.Get("-inkscape-font-specification"); found {
+		for _, word := range strings.FieldsFunc(strings.Trim(spec, `'"`), func(r rune) bool { return r == ' ' || r == ',' }) {
+			switch strings.ToLower(word) {
+			case "bold":
+				req.bold = true
+			case "light":
+				req.light = true
+			case "italic", "oblique":
+				req.italic = true
+			}
 		}
-		return f.getFont(family[1], bold, light, fSize)
 	}
 
-	return f.getFont(defaultFont, false, false, float64(defaultSize))
+	if weight, found := decl.Get("font-weight"); found {
+		req.bold, req.light = fontWeight(weight)
+	}
+
+	if fontStyle, found := decl.Get("font-style"); found {
+		req.italic = fontStyle == "italic" || fontStyle == "oblique"
+	}
+
+	return
 }
 
-func (f *fonts) UseFont(name string) {
-	f.used[name] = f.current[name]
+func (f *fonts) GetFont(style string) (name string, substituted bool) {
+	req := parseFontRequest(style, f.defaultSize)
+
+	if req.italic {
+		fmt.Printf("Warning: italic fonts are not supported, using upright font for '%s'\n", strings.Join(req.families, ", "))
+	}
+
+	if len(req.families) == 0 {
+		return f.getFont(f.defaultFont, req.bold, req.light, req.size)
+	}
+
+	// Use the first family in the list that is available, directly or by an alias, falling back to the default font.
+	for _, family := range req.families {
+		if allowed, found := f.findFamily(family); found {
+			return f.getFont(allowed, req.bold, req.light, req.size)
+		}
+
+		if alias, found := f.aliases[normalizeFamily(family)]; found {
+			if allowed, found := f.findFamily(alias); found {
+				return f.getFont(allowed, req.bold, req.light, req.size)
+			}
+		}
+	}
+
+	size := req.size
+	if f.resetSize {
+		size = float64(f.defaultSize)
+	}
+
+	fmt.Printf("No matching font for '%s', using default %s\n", strings.Join(req.families, ", "), f.defaultFont)
+	name, _ = f.getFont(f.defaultFont, req.bold, req.light, size)
+	return name, true
 }
 
+// normalizeFamily makes family names comparable, ignoring case and spaces such that 'Roboto Mono' matches RobotoMono.
+func normalizeFamily(family string) string {
+	return strings.ToLower(strings.ReplaceAll(family, " ", ""))
+}
+
+// findFamily returns the name of the allowed font matching the family. An exact match is preferred, otherwise
+// the names are tried in sorted order so that the same font is found on every run.
+func (f *fonts) findFamily(family string) (name string, found bool) {
+	if _, found = f.allowed[family]; found {
+		return family, true
+	}
+
+	names := make([]string, 0, len(f.allowed))
+	for name = range f.allowed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	normalized := normalizeFamily(family)
+	for _, name = range names {
+		if normalizeFamily(name) == normalized {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// getFont returns the key of the font of the allowed family, using the regular variant when the requested one
+// isn't available.
 func (f *fonts) getFont(family string, bold, light bool, size float64) (key string, substituted bool) {
-	allowed, found := f.allowed[family]
-	substituted = false
+	allowed := f.allowed[family]
 	name := family
 
-	if found {
-		if !bold && !light && allowed.Regular {
-			name = family
-		} else if bold && allowed.Bold {
+	switch {
+	case bold && allowed.Bold:
+		name = fmt.Sprintf("%s-Bold", family)
+	case light && allowed.Light:
+		name = fmt.Sprintf("%s-Light", family)
+	case !allowed.Regular:
+		substituted = true
+		if allowed.Bold {
 			name = fmt.Sprintf("%s-Bold", family)
-		} else if light && allowed.Light {
+		} else if allowed.Light {
 			name = fmt.Sprintf("%s-Light", family)
-		} else {
-			substituted = true
 		}
-	} else {
+	case bold || light:
 		substituted = true
 	}
 
 	if substituted {
-		fmt.Printf("No matching attributes for font '%s': bold: %v, light: %v, using default %s with size %d\n", family, bold, light, defaultFont, defaultSize)
-		name = defaultFont
-		size = float64(defaultSize)
-		bold = false
-		light = false
+		fmt.Printf("No matching attributes for font '%s': bold: %v, light: %v, using %s\n", family, bold, light, name)
 	}
 
 	fontSize := int(math.Round(size))
@@ -173,3 +317,54 @@ func (f *fonts) getFont(family string, bold, light bool, size float64) (key stri
 
 	return
 }
+
+// MaxFonts is the number of fonts RenderScript can load at once
+const MaxFonts = 8
+
+// consolidateFonts reduces the number of fonts to at most max by merging sizes into the nearest other size of the same
+// font. Each step makes the merge with the least error, where the error is the size difference times the number of
+// texts using the merged font. The returned replacements map each merged font to the one replacing it.
+func consolidateFonts(fonts map[string]*layout.Font, usage map[string]int, max int) (replacements map[string]string, err error) {
+	replacements = map[string]string{}
+
+	remaining := make([]string, 0, len(usage))
+	count := map[string]int{}
+	for key, n := range usage {
+		remaining = append(remaining, key)
+		count[key] = n
+	}
+	sort.Strings(remaining)
+
+	for len(remaining) > max {
+		from, to := -1, -1
+		lowest := math.MaxFloat64
+		for i, a := range remaining {
+			for j, b := range remaining {
+				if i == j || fonts[a].Font != fonts[b].Font {
+					continue
+				}
+
+				cost := float64(count[a]) * math.Abs(float64(fonts[a].Size-fonts[b].Size))
+				if cost < lowest {
+					from, to, lowest = i, j, cost
+				}
+			}
+		}
+
+		if from < 0 {
+			return nil, fmt.Errorf("%d fonts remain after merging sizes, at most %d are supported", len(remaining), max)
+		}
+
+		merged, target := remaining[from], remaining[to]
+		replacements[merged] = target
+		for k, v := range replacements {
+			if v == merged {
+				replacements[k] = target
+			}
+		}
+		count[target] += count[merged]
+		remaining = append(remaining[:from], remaining[from+1:]...)
+	}
+
+	return
+}
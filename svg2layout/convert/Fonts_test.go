@@ -0,0 +1,161 @@
+package convert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PerMalmberg/du-render/svg2layout/layout"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFontRequest(t *testing.T) {
+	tests := []struct {
+		style    string
+		expected fontRequest
+	}{
+		{"", fontRequest{size: 10}},
+		{"font-size:12px", fontRequest{size: 12}},
+		{"font-size:12.6;font-family:Play", fontRequest{families: []string{"Play"}, size: 12.6}},
+		{"font-family:'Roboto Mono', monospace", fontRequest{families: []string{"Roboto Mono", "monospace"}, size: 10}},
+		{"font-weight:bold", fontRequest{bold: true, size: 10}},
+		{"font-weight:700", fontRequest{bold: true, size: 10}},
+		{"font-weight:300", fontRequest{light: true, size: 10}},
+		{"font-weight:lighter", fontRequest{light: true, size: 10}},
+		{"font-weight:normal", fontRequest{size: 10}},
+		{"font-weight:500", fontRequest{size: 10}},
+		{"-inkscape-font-specification:'Montserrat Bold'", fontRequest{bold: true, size: 10}},
+		{"-inkscape-font-specification:Montserrat Light", fontRequest{light: true, size: 10}},
+		{"-inkscape-font-specification:'Play, Bold Italic'", fontRequest{bold: true, italic: true, size: 10}},
+		{"-inkscape-font-specification:'Montserrat Bold';font-weight:normal", fontRequest{size: 10}},
+		{"font-style:italic", fontRequest{italic: true, size: 10}},
+		{"font-style:oblique", fontRequest{italic: true, size: 10}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, parseFontRequest(test.style, 10), test.style)
+	}
+}
+
+func TestGetFont(t *testing.T) {
+	f := NewFonts(FontConfig{
+		Fonts: map[string]FontVariant{
+			"Complete":    {Bold: true, Light: true, Regular: true},
+			"RegularOnly": {Regular: true},
+			"BoldOnly":    {Bold: true},
+		},
+	})
+
+	tests := []struct {
+		style       string
+		name        string
+		substituted bool
+	}{
+		{"font-size:12px;font-family:Complete", "Complete-12", false},
+		{"font-size:12px;font-family:Complete;font-weight:bold", "Complete-Bold-12", false},
+		{"font-size:12px;font-family:Complete;font-weight:300", "Complete-Light-12", false},
+		{"font-size:12px;font-family:Complete;font-weight:bold;font-style:italic", "Complete-Bold-12", false},
+		{"font-size:12px;font-family:RegularOnly;font-weight:bold", "RegularOnly-12", true},
+		{"font-size:12px;font-family:RegularOnly;font-weight:light", "RegularOnly-12", false},
+		{"font-size:12px;font-family:BoldOnly", "BoldOnly-Bold-12", true},
+		{"font-size:12px;font-family:'Roboto Mono', monospace", "RobotoMono-12", false},
+		{"font-size:12px;font-family:Unknown, 'complete'", "Complete-12", false},
+		{"font-size:12px;font-family:Unknown", "RobotoMono-12", true},
+		{"font-size:12.4px", "RobotoMono-12", false},
+		{"font-weight:bold", "RobotoMono-Bold-10", false},
+		{"", "RobotoMono-10", false},
+	}
+
+	for _, test := range tests {
+		name, substituted := f.GetFont(test.style)
+		assert.Equal(t, test.name, name, test.style)
+		assert.Equal(t, test.substituted, substituted, test.style)
+	}
+
+	// Fonts normalizing to the same name are resolved the same way on every run, exact matches first
+	f = NewFonts(FontConfig{Fonts: map[string]FontVariant{"Roboto Mono": {Regular: true}}})
+	for i := 0; i < 20; i++ {
+		name, _ := f.GetFont("font-size:12px;font-family:RobotoMono")
+		assert.Equal(t, "RobotoMono-12", name)
+		name, _ = f.GetFont("font-size:12px;font-family:Roboto Mono")
+		assert.Equal(t, "Roboto Mono-12", name)
+		name, _ = f.GetFont("font-size:12px;font-family:roboto mono")
+		assert.Equal(t, "Roboto Mono-12", name)
+	}
+}
+
+func TestFontConfig(t *testing.T) {
+	config, err := ReadFontConfig(strings.NewReader(`{
+		"fonts": {"Orbitron": {"regular": true, "bold": true}},
+		"aliases": {"DejaVu Sans Mono": "FiraMono", "monospace": "robotomono", "Sci-Fi": "Orbitron"},
+		"defaultFont": "Play",
+		"defaultSize": 14
+	}`))
+	assert.NoError(t, err)
+
+	f := NewFonts(config)
+
+	tests := []struct {
+		style       string
+		name        string
+		substituted bool
+	}{
+		{"font-size:12px;font-family:'DejaVu Sans Mono'", "FiraMono-12", false},
+		{"font-size:12px;font-family:Unknown, monospace", "RobotoMono-12", false},
+		{"font-size:12px;font-family:Sci-Fi;font-weight:bold", "Orbitron-Bold-12", false},
+		{"font-size:12px;font-family:Unknown", "Play-12", true},
+		{"font-family:Orbitron", "Orbitron-14", false},
+	}
+
+	for _, test := range tests {
+		name, substituted := f.GetFont(test.style)
+		assert.Equal(t, test.name, name, test.style)
+		assert.Equal(t, test.substituted, substituted, test.style)
+	}
+
+	// The size may be reset when the default font is substituted
+	config.ResetSize = true
+	name, _ := NewFonts(config).GetFont("font-size:12px;font-family:Unknown")
+	assert.Equal(t, "Play-14", name)
+
+	_, err = ReadFontConfig(strings.NewReader(`{"aliases": {"monospace": "Courier"}}`))
+	assert.ErrorContains(t, err, "font alias 'monospace' refers to unknown font 'Courier'")
+
+	_, err = ReadFontConfig(strings.NewReader(`{"defaultFont": "Courier"}`))
+	assert.ErrorContains(t, err, "unknown default font 'Courier'")
+
+	_, err = ReadFontConfig(strings.NewReader(`{"default": "Play"}`))
+	assert.ErrorContains(t, err, "invalid font config")
+}
+
+func TestConsolidateFonts(t *testing.T) {
+	fonts := map[string]*layout.Font{
+		"Play-10":     {Font: "Play", Size: 10},
+		"Play-14":     {Font: "Play", Size: 14},
+		"Play-20":     {Font: "Play", Size: 20},
+		"Play-Bold-8": {Font: "Play-Bold", Size: 8},
+		"FiraMono-10": {Font: "FiraMono", Size: 10},
+	}
+
+	tests := []struct {
+		usage        map[string]int
+		max          int
+		replacements map[string]string
+		err          string
+	}{
+		{map[string]int{"Play-10": 1, "Play-14": 1}, 2, map[string]string{}, ""},
+		{map[string]int{"Play-10": 5, "Play-14": 1, "Play-20": 1}, 2, map[string]string{"Play-14": "Play-10"}, ""},
+		{map[string]int{"Play-10": 1, "Play-14": 5, "Play-20": 1}, 2, map[string]string{"Play-10": "Play-14"}, ""},
+		{map[string]int{"Play-10": 1, "Play-14": 1, "Play-20": 1}, 1, map[string]string{"Play-10": "Play-14", "Play-20": "Play-14"}, ""},
+		{map[string]int{"Play-10": 1, "Play-Bold-8": 1, "FiraMono-10": 1}, 2, nil, "3 fonts remain after merging sizes, at most 2 are supported"},
+	}
+
+	for _, test := range tests {
+		replacements, err := consolidateFonts(fonts, test.usage, test.max)
+		if test.err != "" {
+			assert.ErrorContains(t, err, test.err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, test.replacements, replacements)
+	}
+}
@@ -0,0 +1,408 @@
+package convert
+
+import (
+	"errors"
+	"math"
+
+	"github.com/PerMalmberg/du-render/svg2layout/svg"
+)
+
+// Maximum recursion depth when subdividing curves
+const maxSubdivisions = 16
+
+// flattenSubPath approximates the curves of the sub path with straight lines, deviating at most tolerance from the curve.
+// The result is the start point followed by the end point of each line.
+func flattenSubPath(sp svg.SubPath, tolerance float64) (points []svg.Point) {
+	if len(sp.Segments) == 0 {
+		return
+	}
+
+	points = append(points, sp.Start())
+
+	for _, seg := range sp.Segments {
+		switch seg.Kind {
+		case svg.LineSegment:
+			points = append(points, seg.End)
+		case svg.QuadraticSegment:
+			// Elevate to a cubic, which is exact.
+			c1 := svg.Point{X: seg.Start.X + 2.0/3.0*(seg.Control1.X-seg.Start.X), Y: seg.Start.Y + 2.0/3.0*(seg.Control1.Y-seg.Start.Y)}
+			c2 := svg.Point{X: seg.End.X + 2.0/3.0*(seg.Control1.X-seg.End.X), Y: seg.End.Y + 2.0/3.0*(seg.Control1.Y-seg.End.Y)}
+			points = flattenCubic(points, seg.Start, c1, c2, seg.End, tolerance, 0)
+		case svg.CubicSegment:
+			points = flattenCubic(points, seg.Start, seg.Control1, seg.Control2, seg.End, tolerance, 0)
+		case svg.ArcSegment:
+			points = flattenArc(points, seg, tolerance)
+		}
+	}
+
+	return
+}
+
+// distanceToLine returns the distance from p to the line through a and b
+func distanceToLine(p, a, b svg.Point) float64 {
+	dx := b.X - a.X
+	dy := b.Y - a.Y
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return math.Hypot(p.X-a.X, p.Y-a.Y)
+	}
+	return math.Abs(dx*(a.Y-p.Y)-dy*(a.X-p.X)) / length
+}
+
+func midPoint(a, b svg.Point) svg.Point {
+	return svg.Point{X: (a.X + b.X) / 2, Y: (a.Y + b.Y) / 2}
+}
+
+// flattenCubic subdivides the curve until it is flat enough, appending the end points of the lines.
+func flattenCubic(points []svg.Point, p0, p1, p2, p3 svg.Point, tolerance float64, depth int) []svg.Point {
+	flat := distanceToLine(p1, p0, p3) <= tolerance && distanceToLine(p2, p0, p3) <= tolerance
+	if flat || depth >= maxSubdivisions {
+		return append(points, p3)
+	}
+
+	// de Casteljau subdivision at t = 0.5
+	p01 := midPoint(p0, p1)
+	p12 := midPoint(p1, p2)
+	p23 := midPoint(p2, p3)
+	p012 := midPoint(p01, p12)
+	p123 := midPoint(p12, p23)
+	mid := midPoint(p012, p123)
+
+	points = flattenCubic(points, p0, p01, p012, mid, tolerance, depth+1)
+	return flattenCubic(points, mid, p123, p23, p3, tolerance, depth+1)
+}
+
+// flattenArc approximates the elliptical arc with lines, see https://www.w3.org/TR/SVG/implnote.html#ArcImplementationNotes
+func flattenArc(points []svg.Point, seg svg.Segment, tolerance float64) []svg.Point {
+	if seg.Start == seg.End {
+		return points
+	}
+
+	rx := seg.Arc.Rx
+	ry := seg.Arc.Ry
+	phi := seg.Arc.Rotation * math.Pi / 180
+	cosPhi := math.Cos(phi)
+	sinPhi := math.Sin(phi)
+
+	// Step 1: compute (x1', y1')
+	dx := (seg.Start.X - seg.End.X) / 2
+	dy := (seg.Start.Y - seg.End.Y) / 2
+	x1 := cosPhi*dx + sinPhi*dy
+	y1 := -sinPhi*dx + cosPhi*dy
+
+	// Ensure radii are large enough
+	lambda := (x1*x1)/(rx*rx) + (y1*y1)/(ry*ry)
+	if lambda > 1 {
+		rx *= math.Sqrt(lambda)
+		ry *= math.Sqrt(lambda)
+	}
+
+	// Step 2: compute (cx', cy')
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	coef := math.Sqrt(math.Max(0, num/den))
+	if seg.Arc.LargeArc == seg.Arc.Sweep {
+		coef = -coef
+	}
+	cx1 := coef * rx * y1 / ry
+	cy1 := -coef * ry * x1 / rx
+
+	// Step 3: compute (cx, cy)
+	cx := cosPhi*cx1 - sinPhi*cy1 + (seg.Start.X+seg.End.X)/2
+	cy := sinPhi*cx1 + cosPhi*cy1 + (seg.Start.Y+seg.End.Y)/2
+
+	// Step 4: compute the start angle and sweep
+	angle := func(ux, uy, vx, vy float64) float64 {
+		a := math.Atan2(uy, ux)
+		b := math.Atan2(vy, vx)
+		return b - a
+	}
+
+	theta1 := angle(1, 0, (x1-cx1)/rx, (y1-cy1)/ry)
+	delta := angle((x1-cx1)/rx, (y1-cy1)/ry, (-x1-cx1)/rx, (-y1-cy1)/ry)
+
+	if seg.Arc.Sweep && delta < 0 {
+		delta += 2 * math.Pi
+	} else if !seg.Arc.Sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	}
+
+	// Choose the step so that the distance between the chord and the arc is within the tolerance.
+	r := math.Max(rx, ry)
+	step := math.Pi / 4
+	if tolerance < r {
+		step = math.Min(step, 2*math.Acos(1-tolerance/r))
+	}
+	count := int(math.Ceil(math.Abs(delta) / step))
+	if count < 1 {
+		count = 1
+	}
+
+	for i := 1; i < count; i++ {
+		t := theta1 + delta*float64(i)/float64(count)
+		x := rx * math.Cos(t)
+		y := ry * math.Sin(t)
+		points = append(points, svg.Point{X: cosPhi*x - sinPhi*y + cx, Y: sinPhi*x + cosPhi*y + cy})
+	}
+
+	// Use the exact end point to avoid rounding errors
+	return append(points, seg.End)
+}
+
+// Maximum number of quadratic curves a cubic curve may be split into before it is flattened instead
+const maxQuadraticsPerCubic = 4
+
+// quadratic is a quadratic curve; start, control and end points.
+type quadratic [3]svg.Point
+
+func lerp(a, b svg.Point, t float64) svg.Point {
+	return svg.Point{X: a.X + (b.X-a.X)*t, Y: a.Y + (b.Y-a.Y)*t}
+}
+
+// splitCubic splits the cubic curve at t using de Casteljau's algorithm.
+func splitCubic(c [4]svg.Point, t float64) (left, right [4]svg.Point) {
+	p01 := lerp(c[0], c[1], t)
+	p12 := lerp(c[1], c[2], t)
+	p23 := lerp(c[2], c[3], t)
+	p012 := lerp(p01, p12, t)
+	p123 := lerp(p12, p23, t)
+	mid := lerp(p012, p123, t)
+
+	return [4]svg.Point{c[0], p01, p012, mid}, [4]svg.Point{mid, p123, p23, c[3]}
+}
+
+// cubicToQuadratics approximates the cubic curve with quadratic curves, deviating at most tolerance from it.
+// ok is false if more than maxQuadraticsPerCubic curves are needed.
+func cubicToQuadratics(p0, p1, p2, p3 svg.Point, tolerance float64) (curves []quadratic, ok bool) {
+	// The distance between the cubic and the quadratic with the control point (3(p1+p2)-(p0+p3))/4 is at most
+	// sqrt(3)/36*|p3-3p2+3p1-p0|, which is divided by n^3 when the cubic is split into n equal parts.
+	dx := p3.X - 3*p2.X + 3*p1.X - p0.X
+	dy := p3.Y - 3*p2.Y + 3*p1.Y - p0.Y
+	maxError := math.Sqrt(3) / 36 * math.Hypot(dx, dy)
+
+	for n := 1; n <= maxQuadraticsPerCubic; n++ {
+		if maxError/float64(n*n*n) > tolerance {
+			continue
+		}
+
+		rest := [4]svg.Point{p0, p1, p2, p3}
+		for i := 0; i < n; i++ {
+			part := rest
+			if i < n-1 {
+				// Split off the next part, scaling t to what remains of the curve
+				part, rest = splitCubic(rest, 1/float64(n-i))
+			}
+
+			control := svg.Point{
+				X: (3*(part[1].X+part[2].X) - part[0].X - part[3].X) / 4,
+				Y: (3*(part[1].Y+part[2].Y) - part[0].Y - part[3].Y) / 4,
+			}
+			curves = append(curves, quadratic{part[0], control, part[3]})
+		}
+
+		return curves, true
+	}
+
+	return
+}
+
+type triangle [3]svg.Point
+type quad [4]svg.Point
+
+// cross returns the z-component of the cross product of (b-a) and (c-a)
+func cross(a, b, c svg.Point) float64 {
+	return (b.X-a.X)*(c.Y-a.Y) - (b.Y-a.Y)*(c.X-a.X)
+}
+
+func signedArea(polygon []svg.Point) float64 {
+	area := float64(0)
+	for i := range polygon {
+		a := polygon[i]
+		b := polygon[(i+1)%len(polygon)]
+		area += a.X*b.Y - b.X*a.Y
+	}
+	return area / 2
+}
+
+// cleanPolygon removes repeated and collinear points, including a closing point equal to the first.
+func cleanPolygon(polygon []svg.Point) []svg.Point {
+	const epsilon = 1e-9
+
+	res := make([]svg.Point, 0, len(polygon))
+	for _, p := range polygon {
+		if len(res) == 0 || p != res[len(res)-1] {
+			res = append(res, p)
+		}
+	}
+
+	for len(res) > 1 && res[0] == res[len(res)-1] {
+		res = res[:len(res)-1]
+	}
+
+	changed := true
+	for changed && len(res) >= 3 {
+		changed = false
+		for i := 0; i < len(res); i++ {
+			prev := res[(i+len(res)-1)%len(res)]
+			next := res[(i+1)%len(res)]
+			if math.Abs(cross(prev, res[i], next)) < epsilon {
+				res = append(res[:i], res[i+1:]...)
+				changed = true
+				break
+			}
+		}
+	}
+
+	return res
+}
+
+// pointInPolygon returns true if the point is inside the polygon, using the even-odd rule.
+func pointInPolygon(p svg.Point, polygon []svg.Point) (inside bool) {
+	for i := range polygon {
+		a := polygon[i]
+		b := polygon[(i+1)%len(polygon)]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < a.X+(p.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y) {
+			inside = !inside
+		}
+	}
+	return
+}
+
+// polygonInside returns true if all corners of the inner polygon are inside the outer polygon.
+func polygonInside(inner, outer []svg.Point) bool {
+	if len(inner) == 0 || len(outer) < 3 {
+		return false
+	}
+
+	for _, p := range inner {
+		if !pointInPolygon(p, outer) {
+			return false
+		}
+	}
+	return true
+}
+
+func pointInTriangle(p, a, b, c svg.Point) bool {
+	// Triangle is counter-clockwise, points on the edges count as inside.
+	return cross(a, b, p) >= 0 && cross(b, c, p) >= 0 && cross(c, a, p) >= 0
+}
+
+// triangulate splits the simple polygon into triangles using ear clipping.
+func triangulate(polygon []svg.Point) (triangles []triangle, err error) {
+	poly := cleanPolygon(polygon)
+
+	if len(poly) < 3 {
+		return
+	}
+
+	// Work on a counter-clockwise (in a y-up system) polygon
+	if signedArea(poly) < 0 {
+		for i, j := 0, len(poly)-1; i < j; i, j = i+1, j-1 {
+			poly[i], poly[j] = poly[j], poly[i]
+		}
+	}
+
+	for len(poly) > 3 {
+		earFound := false
+
+		for i := range poly {
+			prev := poly[(i+len(poly)-1)%len(poly)]
+			curr := poly[i]
+			next := poly[(i+1)%len(poly)]
+
+			// Reflex vertices can't be ears
+			if cross(prev, curr, next) <= 0 {
+				continue
+			}
+
+			isEar := true
+			for _, p := range poly {
+				if p != prev && p != curr && p != next && pointInTriangle(p, prev, curr, next) {
+					isEar = false
+					break
+				}
+			}
+
+			if isEar {
+				triangles = append(triangles, triangle{prev, curr, next})
+				poly = append(poly[:i], poly[i+1:]...)
+				earFound = true
+				break
+			}
+		}
+
+		if !earFound {
+			err = errors.New("unable to triangulate shape, it may be self-intersecting")
+			return
+		}
+	}
+
+	triangles = append(triangles, triangle{poly[0], poly[1], poly[2]})
+	return
+}
+
+func isConvex(q quad) bool {
+	sign := 0
+	for i := range q {
+		c := cross(q[i], q[(i+1)%4], q[(i+2)%4])
+		if c == 0 {
+			continue
+		}
+		s := 1
+		if c < 0 {
+			s = -1
+		}
+		if sign == 0 {
+			sign = s
+		} else if s != sign {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeTriangles joins pairs of triangles sharing an edge into convex quads, reducing the number of components.
+func mergeTriangles(triangles []triangle) (quads []quad, remaining []triangle) {
+	used := make([]bool, len(triangles))
+
+	for i := range triangles {
+		if used[i] {
+			continue
+		}
+
+		for j := i + 1; j < len(triangles) && !used[i]; j++ {
+			if used[j] {
+				continue
+			}
+
+			if q, ok := joinTriangles(triangles[i], triangles[j]); ok && isConvex(q) {
+				quads = append(quads, q)
+				used[i] = true
+				used[j] = true
+			}
+		}
+
+		if !used[i] {
+			remaining = append(remaining, triangles[i])
+		}
+	}
+
+	return
+}
+
+// joinTriangles creates a quad from two triangles if they share an edge.
+func joinTriangles(a, b triangle) (q quad, ok bool) {
+	for i := 0; i < 3; i++ {
+		p := a[i]
+		r := a[(i+1)%3]
+		opposite := a[(i+2)%3]
+		for j := 0; j < 3; j++ {
+			// Triangles with the same orientation share edges in opposite directions
+			if b[j] == r && b[(j+1)%3] == p {
+				return quad{p, b[(j+2)%3], r, opposite}, true
+			}
+		}
+	}
+
+	return
+}
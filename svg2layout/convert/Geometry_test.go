@@ -0,0 +1,123 @@
+package convert
+
+import (
+	"math"
+	"testing"
+
+	"github.com/PerMalmberg/du-render/svg2layout/svg"
+	"github.com/stretchr/testify/assert"
+)
+
+func pt(x, y float64) svg.Point {
+	return svg.Point{X: x, Y: y}
+}
+
+func triangleArea(t triangle) float64 {
+	return math.Abs(cross(t[0], t[1], t[2])) / 2
+}
+
+func TestTriangulate(t *testing.T) {
+	// Concave L-shape, clockwise in the y-down system with a closing point and a collinear point
+	l := []svg.Point{pt(0, 0), pt(2, 0), pt(2, 1), pt(1, 1), pt(1, 2), pt(0, 2), pt(0, 1), pt(0, 0)}
+	triangles, err := triangulate(l)
+	assert.NoError(t, err)
+	assert.Len(t, triangles, 4)
+
+	area := float64(0)
+	for _, tri := range triangles {
+		area += triangleArea(tri)
+	}
+	assert.InDelta(t, 3, area, 1e-9)
+
+	quads, remaining := mergeTriangles(triangles)
+	assert.Len(t, quads, 2)
+	assert.Len(t, remaining, 0)
+	for _, q := range quads {
+		assert.True(t, isConvex(q))
+	}
+
+	// Degenerate
+	triangles, err = triangulate([]svg.Point{pt(0, 0), pt(1, 1), pt(2, 2)})
+	assert.NoError(t, err)
+	assert.Len(t, triangles, 0)
+}
+
+func TestMergeTriangles(t *testing.T) {
+	square, err := triangulate([]svg.Point{pt(0, 0), pt(1, 0), pt(1, 1), pt(0, 1)})
+	assert.NoError(t, err)
+	quads, remaining := mergeTriangles(square)
+	assert.Len(t, quads, 1)
+	assert.Len(t, remaining, 0)
+	assert.InDelta(t, 1, math.Abs(signedArea(quads[0][:])), 1e-9)
+
+	// Arrow head, the two triangles form a concave quad so they're kept
+	arrow, err := triangulate([]svg.Point{pt(0, 0), pt(2, 1), pt(0, 2), pt(1, 1)})
+	assert.NoError(t, err)
+	quads, remaining = mergeTriangles(arrow)
+	assert.Len(t, quads, 0)
+	assert.Len(t, remaining, 2)
+}
+
+func TestPolygonInside(t *testing.T) {
+	outer := []svg.Point{pt(0, 0), pt(100, 0), pt(100, 100), pt(0, 100)}
+	hole := []svg.Point{pt(25, 25), pt(75, 25), pt(75, 75), pt(25, 75)}
+	overlapping := []svg.Point{pt(50, 50), pt(150, 50), pt(150, 150)}
+
+	assert.True(t, polygonInside(hole, outer))
+	assert.False(t, polygonInside(outer, hole))
+	assert.False(t, polygonInside(overlapping, outer))
+
+	// Inside the concave part of an L-shape is outside
+	l := []svg.Point{pt(0, 0), pt(2, 0), pt(2, 1), pt(1, 1), pt(1, 2), pt(0, 2)}
+	assert.True(t, pointInPolygon(pt(0.5, 1.5), l))
+	assert.False(t, pointInPolygon(pt(1.5, 1.5), l))
+}
+
+func TestFlatten(t *testing.T) {
+	// Half circle with radius 10
+	paths, err := svg.ParsePathData("M -10,0 A 10 10 0 0 1 10,0 Q 10,10 0,10")
+	assert.NoError(t, err)
+
+	points := flattenSubPath(paths[0], 0.1)
+	assert.Equal(t, svg.Point{X: -10, Y: 0}, points[0])
+	assert.Equal(t, svg.Point{X: 0, Y: 10}, points[len(points)-1])
+
+	arcEnd := 0
+	for i, p := range points {
+		if p == (svg.Point{X: 10, Y: 0}) {
+			arcEnd = i
+		}
+	}
+	assert.Greater(t, arcEnd, 10)
+
+	for _, p := range points[:arcEnd+1] {
+		assert.InDelta(t, 10, math.Hypot(p.X, p.Y), 1e-9)
+		// Sweeps through negative y, i.e. upwards on screen
+		assert.LessOrEqual(t, p.Y, 1e-9)
+	}
+
+	// A tighter tolerance gives more points
+	assert.Greater(t, len(flattenSubPath(paths[0], 0.01)), len(points))
+}
+
+func TestCubicToQuadratics(t *testing.T) {
+	// A quadratic elevated to a cubic is represented exactly by a single quadratic
+	curves, ok := cubicToQuadratics(pt(0, 0), pt(20, 20), pt(40, 20), pt(60, 0), 0.1)
+	assert.True(t, ok)
+	assert.Len(t, curves, 1)
+	assert.Equal(t, quadratic{pt(0, 0), pt(30, 30), pt(60, 0)}, curves[0])
+
+	// A curve deviating a bit more is split, keeping the end points and the continuity between the parts
+	curves, ok = cubicToQuadratics(pt(0, 0), pt(0, 50), pt(100, 50), pt(100, 0), 0.5)
+	assert.True(t, ok)
+	assert.Greater(t, len(curves), 1)
+	assert.Equal(t, pt(0, 0), curves[0][0])
+	assert.InDelta(t, 100, curves[len(curves)-1][2].X, 1e-9)
+	for i := 1; i < len(curves); i++ {
+		assert.Equal(t, curves[i-1][2], curves[i][0])
+	}
+
+	// An S-shaped curve can't be approximated with few quadratics
+	_, ok = cubicToQuadratics(pt(0, 0), pt(300, -300), pt(-300, 300), pt(0, 0), 0.1)
+	assert.False(t, ok)
+}
@@ -0,0 +1,150 @@
+package convert
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/PerMalmberg/du-render/svg2layout/layout"
+	"github.com/PerMalmberg/du-render/svg2layout/svg"
+)
+
+// RenderScript shadows are specified by their radius, which is made to cover most of the Gaussian blur.
+const shadowRadiusPerDeviation = 2
+
+var filterUrlExp = regexp.MustCompile(`^url\(\s*['"]?#([^'")]+)['"]?\s*\)$`)
+
+// shadowFromFilter derives a shadow from a drop shadow filter, either a feDropShadow or the chain of
+// feGaussianBlur, feOffset and feFlood created by Inkscape. An error is returned for other filters.
+// The distance of the shadow is in the user units of the element the filter is applied to.
+func shadowFromFilter(filter svg.Filter) (shadow *layout.Shadow, err error) {
+	var drop, blur, offset, flood *svg.FilterPrimitive
+
+	for i := range filter.Primitives {
+		p := &filter.Primitives[i]
+		switch p.XMLName.Local {
+		case "feDropShadow":
+			drop = p
+		case "feGaussianBlur":
+			if blur != nil {
+				err = fmt.Errorf("multiple blurs are not supported")
+				return
+			}
+			blur = p
+		case "feOffset":
+			offset = p
+		case "feFlood":
+			flood = p
+		case "feComposite", "feMerge", "feBlend":
+			// These combine the shadow with the element itself
+		default:
+			err = fmt.Errorf("unsupported filter primitive %s", p.XMLName.Local)
+			return
+		}
+	}
+
+	if drop != nil {
+		if len(filter.Primitives) > 1 {
+			err = fmt.Errorf("feDropShadow can't be combined with other primitives")
+			return
+		}
+		// feDropShadow is its own offset and flood
+		blur, offset, flood = drop, drop, drop
+	} else if blur == nil || (flood == nil && blur.In != "SourceAlpha") {
+		err = fmt.Errorf("not a drop shadow")
+		return
+	}
+
+	var deviation []float64
+	if deviation, err = svg.ParseNumbers(blur.StdDeviation); err != nil {
+		return
+	}
+
+	radius := float64(0)
+	for _, v := range deviation {
+		// Separate deviations for x and y are averaged
+		radius += v * shadowRadiusPerDeviation / float64(len(deviation))
+	}
+
+	// The shadow is black, unless flooded with another color
+	color := &layout.Color{Alpha: 1}
+	if flood != nil {
+		if color, err = floodColor(*flood); err != nil {
+			return
+		}
+	}
+
+	if offset != nil && (offset.Dx != "" && offset.Dx != "0" || offset.Dy != "" && offset.Dy != "0") {
+		fmt.Printf("Warning: offset (%s,%s) of shadow filter '%s' is not supported, the shadow is centered\n", offset.Dx, offset.Dy, filter.Id)
+	}
+
+	shadow = &layout.Shadow{
+		ColorAndDistance: layout.ColorAndDistance{
+			Color:    *color,
+			Distance: radius,
+		},
+	}
+
+	return
+}
+
+// floodColor returns the color of the flood, which may be specified as attributes or in the style.
+func floodColor(p svg.FilterPrimitive) (c *layout.Color, err error) {
+	style := svg.ParseDeclarations(p.Style)
+
+	value := "black"
+	if v, found := style.Get("flood-color"); found {
+		value = v
+	} else if p.FloodColor != "" {
+		value = p.FloodColor
+	}
+
+	if c, err = layout.ParseColor(value); err != nil {
+		return
+	}
+
+	if c == nil {
+		c = &layout.Color{}
+	}
+
+	opacity := p.FloodOpacity
+	if v, found := style.Get("flood-opacity"); found {
+		opacity = v
+	}
+
+	if opacity != "" {
+		var a float64
+		if a, err = layout.ParseOpacity(opacity); err != nil {
+			return
+		}
+		c.Alpha = layout.RoundToNearest(c.Alpha*a, 3)
+	}
+
+	return
+}
+
+// elementShadow returns the shadow for the filter property of an element, nil if the filter isn't a drop shadow.
+func (c *converter) elementShadow(filterRef string) *layout.Shadow {
+	m := filterUrlExp.FindStringSubmatch(filterRef)
+	if m == nil {
+		fmt.Printf("Warning: filter '%s' is not supported and is ignored\n", filterRef)
+		return nil
+	}
+
+	id := m[1]
+	if shadow, found := c.shadows[id]; found {
+		return shadow
+	}
+
+	var shadow *layout.Shadow
+	if filter, found := c.image.GetFilterById(id); !found {
+		fmt.Printf("Warning: referenced filter '%s' doesn't exist\n", id)
+	} else if s, err := shadowFromFilter(filter); err != nil {
+		fmt.Printf("Warning: filter '%s' is ignored: %v\n", id, err)
+	} else {
+		shadow = s
+	}
+
+	// Only warn once per filter
+	c.shadows[id] = shadow
+	return shadow
+}
@@ -0,0 +1,388 @@
+package layout
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// namedColors are the CSS color keywords
+var namedColors = map[string]string{
+	"aliceblue":            "f0f8ff",
+	"antiquewhite":         "faebd7",
+	"aqua":                 "00ffff",
+	"aquamarine":           "7fffd4",
+	"azure":                "f0ffff",
+	"beige":                "f5f5dc",
+	"bisque":               "ffe4c4",
+	"black":                "000000",
+	"blanchedalmond":       "ffebcd",
+	"blue":                 "0000ff",
+	"blueviolet":           "8a2be2",
+	"brown":                "a52a2a",
+	"burlywood":            "deb887",
+	"cadetblue":            "5f9ea0",
+	"chartreuse":           "7fff00",
+	"chocolate":            "d2691e",
+	"coral":                "ff7f50",
+	"cornflowerblue":       "6495ed",
+	"cornsilk":             "fff8dc",
+	"crimson":              "dc143c",
+	"cyan":                 "00ffff",
+	"darkblue":             "00008b",
+	"darkcyan":             "008b8b",
+	"darkgoldenrod":        "b8860b",
+	"darkgray":             "a9a9a9",
+	"darkgreen":            "006400",
+	"darkgrey":             "a9a9a9",
+	"darkkhaki":            "bdb76b",
+	"darkmagenta":          "8b008b",
+	"darkolivegreen":       "556b2f",
+	"darkorange":           "ff8c00",
+	"darkorchid":           "9932cc",
+	"darkred":              "8b0000",
+	"darksalmon":           "e9967a",
+	"darkseagreen":         "8fbc8f",
+	"darkslateblue":        "483d8b",
+	"darkslategray":        "2f4f4f",
+	"darkslategrey":        "2f4f4f",
+	"darkturquoise":        "00ced1",
+	"darkviolet":           "9400d3",
+	"deeppink":             "ff1493",
+	"deepskyblue":          "00bfff",
+	"dimgray":              "696969",
+	"dimgrey":              "696969",
+	"dodgerblue":           "1e90ff",
+	"firebrick":            "b22222",
+	"floralwhite":          "fffaf0",
+	"forestgreen":          "228b22",
+	"fuchsia":              "ff00ff",
+	"gainsboro":            "dcdcdc",
+	"ghostwhite":           "f8f8ff",
+	"gold":                 "ffd700",
+	"goldenrod":            "daa520",
+	"gray":                 "808080",
+	"green":                "008000",
+	"greenyellow":          "adff2f",
+	"grey":                 "808080",
+	"honeydew":             "f0fff0",
+	"hotpink":              "ff69b4",
+	"indianred":            "cd5c5c",
+	"indigo":               "4b0082",
+	"ivory":                "fffff0",
+	"khaki":                "f0e68c",
+	"lavender":             "e6e6fa",
+	"lavenderblush":        "fff0f5",
+	"lawngreen":            "7cfc00",
+	"lemonchiffon":         "fffacd",
+	"lightblue":            "add8e6",
+	"lightcoral":           "f08080",
+	"lightcyan":            "e0ffff",
+	"lightgoldenrodyellow": "fafad2",
+	"lightgray":            "d3d3d3",
+	"lightgreen":           "90ee90",
+	"lightgrey":            "d3d3d3",
+	"lightpink":            "ffb6c1",
+	"lightsalmon":          "ffa07a",
+	"lightseagreen":        "20b2aa",
+	"lightskyblue":         "87cefa",
+	"lightslategray":       "778899",
+	"lightslategrey":       "778899",
+	"lightsteelblue":       "b0c4de",
+	"lightyellow":          "ffffe0",
+	"lime":                 "00ff00",
+	"limegreen":            "32cd32",
+	"linen":                "faf0e6",
+	"magenta":              "ff00ff",
+	"maroon":               "800000",
+	"mediumaquamarine":     "66cdaa",
+	"mediumblue":           "0000cd",
+	"mediumorchid":         "ba55d3",
+	"mediumpurple":         "9370db",
+	"mediumseagreen":       "3cb371",
+	"mediumslateblue":      "7b68ee",
+	"mediumspringgreen":    "00fa9a",
+	"mediumturquoise":      "48d1cc",
+	"mediumvioletred":      "c71585",
+	"midnightblue":         "191970",
+	"mintcream":            "f5fffa",
+	"mistyrose":            "ffe4e1",
+	"moccasin":             "ffe4b5",
+	"navajowhite":          "ffdead",
+	"navy":                 "000080",
+	"oldlace":              "fdf5e6",
+	"olive":                "808000",
+	"olivedrab":            "6b8e23",
+	"orange":               "ffa500",
+	"orangered":            "ff4500",
+	"orchid":               "da70d6",
+	"palegoldenrod":        "eee8aa",
+	"palegreen":            "98fb98",
+	"paleturquoise":        "afeeee",
+	"palevioletred":        "db7093",
+	"papayawhip":           "ffefd5",
+	"peachpuff":            "ffdab9",
+	"peru":                 "cd853f",
+	"pink":                 "ffc0cb",
+	"plum":                 "dda0dd",
+	"powderblue":           "b0e0e6",
+	"purple":               "800080",
+	"rebeccapurple":        "663399",
+	"red":                  "ff0000",
+	"rosybrown":            "bc8f8f",
+	"royalblue":            "4169e1",
+	"saddlebrown":          "8b4513",
+	"salmon":               "fa8072",
+	"sandybrown":           "f4a460",
+	"seagreen":             "2e8b57",
+	"seashell":             "fff5ee",
+	"sienna":               "a0522d",
+	"silver":               "c0c0c0",
+	"skyblue":              "87ceeb",
+	"slateblue":            "6a5acd",
+	"slategray":            "708090",
+	"slategrey":            "708090",
+	"snow":                 "fffafa",
+	"springgreen":          "00ff7f",
+	"steelblue":            "4682b4",
+	"tan":                  "d2b48c",
+	"teal":                 "008080",
+	"thistle":              "d8bfd8",
+	"tomato":               "ff6347",
+	"turquoise":            "40e0d0",
+	"violet":               "ee82ee",
+	"wheat":                "f5deb3",
+	"white":                "ffffff",
+	"whitesmoke":           "f5f5f5",
+	"yellow":               "ffff00",
+	"yellowgreen":          "9acd32",
+}
+
+// ParseColor parses a CSS color, such as #rgb, #rrggbbaa, rgb(), rgba(), hsl(), hsla() or a named color.
+// "none" results in no color. currentColor must be resolved by the caller as it depends on the color property.
+func ParseColor(value string) (c *Color, err error) {
+	v := strings.ToLower(strings.TrimSpace(value))
+
+	if hex, found := namedColors[v]; found {
+		v = "#" + hex
+	}
+
+	switch {
+	case v == "none" || v == "":
+		return
+	case v == "transparent":
+		c = &Color{}
+	case strings.HasPrefix(v, "#"):
+		c, err = parseHexColor(v[1:])
+	case strings.HasPrefix(v, "rgb(") || strings.HasPrefix(v, "rgba("):
+		c, err = parseRgbColor(v)
+	case strings.HasPrefix(v, "hsl(") || strings.HasPrefix(v, "hsla("):
+		c, err = parseHslColor(v)
+	case strings.HasPrefix(v, "url("):
+		err = fmt.Errorf("unsupported color '%s', gradients and patterns are not supported", value)
+		return
+	default:
+		err = fmt.Errorf("invalid color '%s'", value)
+		return
+	}
+
+	if err != nil {
+		err = fmt.Errorf("invalid color '%s': %v", value, err)
+		c = nil
+		return
+	}
+
+	c.Red = RoundToNearest(c.Red, 3)
+	c.Green = RoundToNearest(c.Green, 3)
+	c.Blue = RoundToNearest(c.Blue, 3)
+	c.Alpha = RoundToNearest(c.Alpha, 3)
+	return
+}
+
+// ParseOpacity parses an opacity given as a number or a percentage, clamped to 0...1
+func ParseOpacity(value string) (opacity float64, err error) {
+	v := strings.TrimSpace(value)
+	div := 1.0
+	if strings.HasSuffix(v, "%") {
+		v = strings.TrimSuffix(v, "%")
+		div = 100
+	}
+
+	if opacity, err = strconv.ParseFloat(v, 64); err != nil {
+		err = fmt.Errorf("invalid opacity '%s'", value)
+		return
+	}
+
+	opacity = clamp(opacity / div)
+	return
+}
+
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+func parseHexColor(hex string) (c *Color, err error) {
+	// Expand the short forms, #rgb and #rgba
+	if len(hex) == 3 || len(hex) == 4 {
+		long := make([]byte, 0, len(hex)*2)
+		for i := range hex {
+			long = append(long, hex[i], hex[i])
+		}
+		hex = string(long)
+	}
+
+	if len(hex) != 6 && len(hex) != 8 {
+		err = fmt.Errorf("expected 3, 4, 6 or 8 hexadecimal digits")
+		return
+	}
+
+	var channels []float64
+	for i := 0; i < len(hex); i += 2 {
+		var v uint64
+		if v, err = strconv.ParseUint(hex[i:i+2], 16, 8); err != nil {
+			return
+		}
+		// SVG is 0-255, but DU uses 0-1 (and 1...5 for HDR)
+		channels = append(channels, float64(v)/255.0)
+	}
+
+	c = &Color{Red: channels[0], Green: channels[1], Blue: channels[2], Alpha: 1}
+	if len(channels) == 4 {
+		c.Alpha = channels[3]
+	}
+
+	return
+}
+
+// colorArguments splits the arguments of a color function, given in either the legacy comma separated
+// form, "rgba(r, g, b, a)", or the space separated one, "rgb(r g b / a)".
+func colorArguments(value string) (args []string, alpha string, err error) {
+	start := strings.Index(value, "(")
+	if !strings.HasSuffix(value, ")") {
+		err = fmt.Errorf("missing ')'")
+		return
+	}
+
+	inner := value[start+1 : len(value)-1]
+	if before, after, found := strings.Cut(inner, "/"); found {
+		inner = before
+		alpha = strings.TrimSpace(after)
+	}
+
+	args = strings.FieldsFunc(inner, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+
+	if len(args) == 4 && alpha == "" {
+		alpha = args[3]
+		args = args[:3]
+	}
+
+	if len(args) != 3 {
+		err = fmt.Errorf("expected 3 or 4 arguments")
+	}
+
+	return
+}
+
+// parseNumberOrPercentage parses a number, or a percentage which is scaled to 0...scale
+func parseNumberOrPercentage(value string, scale float64) (v float64, err error) {
+	if strings.HasSuffix(value, "%") {
+		if v, err = strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64); err != nil {
+			return
+		}
+		return v / 100 * scale, nil
+	}
+
+	return strconv.ParseFloat(value, 64)
+}
+
+func parseRgbColor(value string) (c *Color, err error) {
+	var args []string
+	var alpha string
+	if args, alpha, err = colorArguments(value); err != nil {
+		return
+	}
+
+	channels := make([]float64, 3)
+	for i, arg := range args {
+		var v float64
+		if v, err = parseNumberOrPercentage(arg, 255); err != nil {
+			return
+		}
+		channels[i] = clamp(v / 255)
+	}
+
+	c = &Color{Red: channels[0], Green: channels[1], Blue: channels[2], Alpha: 1}
+	if alpha != "" {
+		c.Alpha, err = ParseOpacity(alpha)
+	}
+
+	return
+}
+
+func parseHslColor(value string) (c *Color, err error) {
+	var args []string
+	var alpha string
+	if args, alpha, err = colorArguments(value); err != nil {
+		return
+	}
+
+	var h, s, l float64
+	if h, err = parseHue(args[0]); err != nil {
+		return
+	}
+
+	if s, err = parseNumberOrPercentage(args[1], 100); err != nil {
+		return
+	}
+
+	if l, err = parseNumberOrPercentage(args[2], 100); err != nil {
+		return
+	}
+
+	s = clamp(s / 100)
+	l = clamp(l / 100)
+
+	// See https://www.w3.org/TR/css-color-4/#hsl-to-rgb
+	f := func(n float64) float64 {
+		k := math.Mod(n+h/30, 12)
+		a := s * math.Min(l, 1-l)
+		return l - a*math.Max(-1, math.Min(k-3, math.Min(9-k, 1)))
+	}
+
+	c = &Color{Red: f(0), Green: f(8), Blue: f(4), Alpha: 1}
+	if alpha != "" {
+		c.Alpha, err = ParseOpacity(alpha)
+	}
+
+	return
+}
+
+// parseHue parses a hue angle into degrees in the range 0...360
+func parseHue(value string) (h float64, err error) {
+	factor := 1.0
+	for _, unit := range []struct {
+		suffix string
+		factor float64
+	}{{"deg", 1}, {"grad", 0.9}, {"rad", 180 / math.Pi}, {"turn", 360}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSuffix(value, unit.suffix)
+			factor = unit.factor
+			break
+		}
+	}
+
+	if h, err = strconv.ParseFloat(value, 64); err != nil {
+		return
+	}
+
+	h = math.Mod(h*factor, 360)
+	if h < 0 {
+		h += 360
+	}
+
+	return
+}
@@ -0,0 +1,90 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected Color
+	}{
+		{"#ff0000", Color{1, 0, 0, 1}},
+		{"#F00", Color{1, 0, 0, 1}},
+		{"#0f08", Color{0, 1, 0, 0.533}},
+		{"#0000ff80", Color{0, 0, 1, 0.502}},
+		{"rgb(255, 0, 0)", Color{1, 0, 0, 1}},
+		{"rgb(100%, 50%, 0%)", Color{1, 0.5, 0, 1}},
+		{"rgba(0,0,255,0.25)", Color{0, 0, 1, 0.25}},
+		{"rgb(0 255 0 / 50%)", Color{0, 1, 0, 0.5}},
+		{"hsl(120, 100%, 50%)", Color{0, 1, 0, 1}},
+		{"hsla(240deg 100% 50% / 0.5)", Color{0, 0, 1, 0.5}},
+		{"hsl(0.5turn, 100%, 25%)", Color{0, 0.5, 0.5, 1}},
+		{"white", Color{1, 1, 1, 1}},
+		{"RebeccaPurple", Color{0.4, 0.2, 0.6, 1}},
+		{"transparent", Color{0, 0, 0, 0}},
+	}
+
+	for _, test := range tests {
+		c, err := ParseColor(test.value)
+		assert.NoError(t, err, test.value)
+		if assert.NotNil(t, c, test.value) {
+			assert.Equal(t, test.expected, *c, test.value)
+		}
+	}
+
+	c, err := ParseColor("none")
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+
+	for _, invalid := range []string{"url(#gradient)", "#12345", "rgb(1,2)", "hsl(x, 1%, 1%)", "notacolor"} {
+		_, err = ParseColor(invalid)
+		assert.Error(t, err, invalid)
+	}
+
+	_, err = ParseColor("url(#linearGradient1)")
+	assert.ErrorContains(t, err, "gradients")
+}
+
+func TestColorFromStyle(t *testing.T) {
+	// Alpha of the color is combined with the opacity
+	c, err := FillFromStyle("fill:rgba(255,0,0,0.5);fill-opacity:0.5")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0.25, c.Alpha)
+
+	c, err = FillFromStyle("color:#00ff00;fill:currentColor")
+	assert.NoError(t, err)
+	assert.Equal(t, Color{0, 1, 0, 1}, *c)
+
+	c, err = FillFromStyle("fill:none;stroke:#ffffff")
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+
+	s, err := StrokeFromStyle("stroke:blue;stroke-width:2px;stroke-opacity:50%")
+	assert.NoError(t, err)
+	assert.Equal(t, Color{0, 0, 1, 0.5}, s.Color)
+	assert.EqualValues(t, 2, s.Distance)
+
+	_, err = FillFromStyle("fill:url(#linearGradient1)")
+	assert.Error(t, err)
+}
+
+func TestColorIntensity(t *testing.T) {
+	c, err := FillFromStyle("fill:#ff8000;-du-fill-intensity:3")
+	assert.NoError(t, err)
+	assert.Equal(t, Color{3, 1.506, 0, 1}, *c)
+
+	// Channels are limited to the HDR range
+	s, err := StrokeFromStyle("stroke:#ffffff;stroke-width:1;-du-stroke-intensity:10;-du-fill-intensity:2")
+	assert.NoError(t, err)
+	assert.Equal(t, Color{5, 5, 5, 1}, s.Color)
+
+	text, err := s.Color.MarshalText()
+	assert.NoError(t, err)
+	assert.Equal(t, "r5.000,g5.000,b5.000,a1.000", string(text))
+
+	_, err = FillFromStyle("fill:#ffffff;-du-fill-intensity:-1")
+	assert.Error(t, err)
+}
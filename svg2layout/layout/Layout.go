@@ -6,6 +6,7 @@ import (
 	"math"
 	"regexp"
 	"strconv"
+	"strings"
 )
 
 type Font struct {
@@ -86,9 +87,6 @@ type Style struct {
 func (s *Style) FromInlineCSS(style string) (err error) {
 	// Extract the different parts from the Text property
 
-	align := "h0,v1" // Left, Top, see RSAlightHor and RSAlignVer
-	s.Align = &align
-
 	if s.Fill, err = FillFromStyle(style); err != nil {
 		return
 	}
@@ -139,85 +137,158 @@ func RoundToNearest(f float64, decimals int) float64 {
 	return math.Round(f*p) / p
 }
 
+// Horizontal alignments, see RSAlignHor
+var textAnchors = map[string]int{
+	"start":  0, // Left
+	"middle": 1, // Center
+	"end":    2, // Right
+}
+
+// Vertical alignments, see RSAlignVer
+var baselines = map[string]int{
+	"text-before-edge": 0, // Ascender
+	"text-top":         0,
+	"hanging":          1, // Top
+	"middle":           2, // Middle
+	"central":          2,
+	"mathematical":     2,
+	"auto":             3, // Baseline
+	"alphabetic":       3,
+	"baseline":         3,
+	"ideographic":      4, // Bottom
+	"text-after-edge":  5, // Descender
+	"text-bottom":      5,
+}
+
+// AlignFromStyle returns the text alignment, "hX,vY", for the text-anchor, direction, dominant-baseline and
+// alignment-baseline properties. SVG texts are positioned at their baseline unless specified otherwise.
+func AlignFromStyle(style string) string {
+	anchor, _ := styleValue(style, "text-anchor")
+	h, found := textAnchors[anchor]
+	if !found {
+		h = textAnchors["start"]
+	}
+
+	// Right-to-left texts start at the right
+	if direction, _ := styleValue(style, "direction"); direction == "rtl" && h != textAnchors["middle"] {
+		h = 2 - h
+	}
+
+	v := baselines["auto"]
+	for _, property := range []string{"dominant-baseline", "alignment-baseline"} {
+		if value, found := styleValue(style, property); found {
+			if b, known := baselines[value]; known && value != "auto" {
+				v = b
+			}
+		}
+	}
+
+	return fmt.Sprintf("h%d,v%d", h, v)
+}
+
 func FillFromStyle(style string) (c *Color, err error) {
-	var fillExp = regexp.MustCompile(`fill:#([0-9a-fA-F]{2})([0-9a-fA-F]{2})([0-9a-fA-F]{2})`)
-	var fillOpacityExp = regexp.MustCompile(`fill-opacity:(\d*\.?\d*)`)
-	return hexToColor(style, fillExp, fillOpacityExp)
+	return colorFromStyle(style, "fill", "fill-opacity", "-du-fill-intensity")
 }
 
 func StrokeFromStyle(style string) (cd *Stroke, err error) {
 	// stroke-linejoin:miter;stroke-linecap:butt;stroke-width:1;fill-opacity:0.55045873;fill:#28a745;stroke:#e02c9f;stroke-opacity:1
-	var strokeExp = regexp.MustCompile(`stroke:#([0-9a-fA-F]{2})([0-9a-fA-F]{2})([0-9a-fA-F]{2})`)
-	var strokeOpacityExp = regexp.MustCompile(`stroke-opacity:(\d*\.?\d*)`)
-	var strokeWidthExp = regexp.MustCompile(`stroke-width:(\d*\.?\d*)`)
 	var color *Color
 
-	if color, err = hexToColor(style, strokeExp, strokeOpacityExp); err != nil {
+	if color, err = colorFromStyle(style, "stroke", "stroke-opacity", "-du-stroke-intensity"); err != nil {
 		return
 	}
 
-	// Stroke may be "none", this is probably the case when color is nil.
+	// Stroke may be "none", this is the case when color is nil.
 	if color != nil {
-		distance := float64(0)
-		widthVal := strokeWidthExp.FindStringSubmatch(style)
-		if len(widthVal) == 2 {
-			if distance, err = strconv.ParseFloat(widthVal[1], 32); err != nil {
+		// The initial stroke-width is 1
+		distance := float64(1)
+		if width, found := styleValue(style, "stroke-width"); found {
+			if distance, err = strconv.ParseFloat(strings.TrimSuffix(width, "px"), 64); err != nil {
+				err = fmt.Errorf("invalid stroke-width '%s'", width)
 				return
 			}
-			cd = &Stroke{
-				ColorAndDistance: ColorAndDistance{
-					Color:    *color,
-					Distance: RoundToNearest(distance, 3),
-				},
-			}
+		}
+
+		cd = &Stroke{
+			ColorAndDistance: ColorAndDistance{
+				Color:    *color,
+				Distance: RoundToNearest(distance, 3),
+			},
 		}
 	}
 
 	return
 }
 
-func hexToColor(style string, fillExp, opacityExp *regexp.Regexp) (c *Color, err error) {
-	fillVal := fillExp.FindStringSubmatch(style)
-
-	if len(fillVal) == 4 {
-		var r, g, b int64
-		if r, err = strconv.ParseInt(fillVal[1], 16, 0); err != nil {
-			return
+// styleValue returns the value of the property in the inline CSS, the last declaration wins.
+func styleValue(style, property string) (value string, found bool) {
+	for _, part := range strings.Split(style, ";") {
+		if p, v, ok := strings.Cut(part, ":"); ok && strings.TrimSpace(p) == property {
+			value = strings.TrimSpace(v)
+			found = true
 		}
+	}
 
-		if g, err = strconv.ParseInt(fillVal[2], 16, 0); err != nil {
-			return
-		}
+	return
+}
 
-		if b, err = strconv.ParseInt(fillVal[3], 16, 0); err != nil {
-			return
+// colorFromStyle parses the color of the property, with the alpha of the color combined with the opacity property
+// and the red, green and blue channels scaled by the intensity property.
+func colorFromStyle(style, property, opacityProperty, intensityProperty string) (c *Color, err error) {
+	value, found := styleValue(style, property)
+	if !found {
+		return
+	}
+
+	if strings.EqualFold(value, "currentColor") {
+		// The initial value of color is black
+		if value, found = styleValue(style, "color"); !found || strings.EqualFold(value, "currentColor") {
+			value = "black"
 		}
+	}
 
-		// SVG is 0-255, but DU uses 0-1 (and 1...5 for HDR)
-		div := 255.0
+	if c, err = ParseColor(value); err != nil {
+		err = fmt.Errorf("%s: %v", property, err)
+		return
+	}
+
+	if c == nil {
+		return
+	}
 
-		c = &Color{
-			Red:   RoundToNearest(float64(r)/div, 3),
-			Green: RoundToNearest(float64(g)/div, 3),
-			Blue:  RoundToNearest(float64(b)/div, 3),
-			Alpha: 1,
+	if opacity, found := styleValue(style, opacityProperty); found {
+		var a float64
+		if a, err = ParseOpacity(opacity); err != nil {
+			err = fmt.Errorf("%s: %v", opacityProperty, err)
+			return
 		}
 
-		opacityVal := opacityExp.FindStringSubmatch(style)
-		if len(opacityVal) == 2 {
-			var a float64
-			if a, err = strconv.ParseFloat(opacityVal[1], 32); err != nil {
-				return
-			}
+		// Round to nearest, three decimal places
+		c.Alpha = RoundToNearest(c.Alpha*a, 3)
+	}
 
-			// Round to nearest, three decimal places
-			c.Alpha = RoundToNearest(a, 3)
+	if intensity, found := styleValue(style, intensityProperty); found {
+		var i float64
+		if i, err = strconv.ParseFloat(intensity, 64); err != nil || i < 0 {
+			err = fmt.Errorf("invalid %s '%s', expected a non-negative number", intensityProperty, intensity)
+			return
 		}
+
+		c.Red = hdrChannel(c.Red * i)
+		c.Green = hdrChannel(c.Green * i)
+		c.Blue = hdrChannel(c.Blue * i)
 	}
 
 	return
 }
 
+// MaxIntensity is the maximum value of the red, green and blue channels; values above 1 make the color glow.
+const MaxIntensity = 5.0
+
+func hdrChannel(v float64) float64 {
+	return RoundToNearest(math.Min(v, MaxIntensity), 3)
+}
+
 type MouseClick struct {
 	Command string `json:"command,omitempty"`
 }
@@ -241,48 +312,99 @@ func (v Vec2) MarshalText() (text []byte, err error) {
 }
 
 type outputComponent struct {
-	Type         string   `json:"type,omitempty"`
-	Layer        int      `json:"layer,omitempty"`
-	Visible      bool     `json:"visible,omitempty"`
-	Pos1         string   `json:"pos1,omitempty"`
-	Pos2         *string  `json:"pos2,omitempty"`
-	CornerRadius *float64 `json:"corner_radius,omitempty"`
-	Radius       *float64 `json:"radius,omitempty"`
-	Style        *string  `json:"style,omitempty"`
-	Mouse        *Mouse   `json:"mouse,omitempty"`
-	Font         *string  `json:"font,omitempty"`
-	Text         *string  `json:"text,omitempty"`
+	Type          string      `json:"type,omitempty"`
+	Layer         int         `json:"layer,omitempty"`
+	Visible       interface{} `json:"visible"`
+	Hitable       *string     `json:"hitable,omitempty"`
+	Pos1          string      `json:"pos1,omitempty"`
+	Pos2          *string     `json:"pos2,omitempty"`
+	Pos3          *string     `json:"pos3,omitempty"`
+	Pos4          *string     `json:"pos4,omitempty"`
+	CornerRadius  *float64    `json:"corner_radius,omitempty"`
+	Radius        *float64    `json:"radius,omitempty"`
+	Style         *string     `json:"style,omitempty"`
+	Mouse         *Mouse      `json:"mouse,omitempty"`
+	Font          *string     `json:"font,omitempty"`
+	Text          *string     `json:"text,omitempty"`
+	Url           *string     `json:"url,omitempty"`
+	Dimensions    *string     `json:"dimensions,omitempty"`
+	Sub           *string     `json:"sub,omitempty"`
+	SubDimensions *string     `json:"subDimensions,omitempty"`
 }
 
 type Component struct {
-	Type         string
-	Layer        int
-	Visible      bool
-	Pos1         string
-	Pos2         *string
-	CornerRadius *float64
-	Radius       *float64
-	Style        *string
-	Mouse        *Mouse
-	Font         *string
-	Text         *string
+	Type          string
+	Layer         int
+	Visible       bool
+	Pos1          string
+	Pos2          *string
+	Pos3          *string
+	Pos4          *string
+	CornerRadius  *float64
+	Radius        *float64
+	Style         *string
+	Mouse         *Mouse
+	Font          *string
+	Text          *string
+	Url           *string
+	Dimensions    *string
+	Sub           *string
+	SubDimensions *string
 
 	Bindings map[string]string
 }
 
+// commonBindings are the properties that can be bound on all components
+var commonBindings = []string{"visible", "hitable", "style", "mouse_click", "mouse_inside"}
+
+// typeBindings are the properties that can be bound per component type, in addition to the common ones
+var typeBindings = map[string][]string{
+	"box":      {"pos1", "pos2"},
+	"text":     {"pos1", "text"},
+	"line":     {"pos1", "pos2"},
+	"circle":   {"pos1"},
+	"triangle": {"pos1", "pos2", "pos3"},
+	"bezier":   {"pos1", "pos2", "pos3"},
+	"quad":     {"pos1", "pos2", "pos3", "pos4"},
+	"image":    {"pos1", "dimensions", "sub", "subDimensions"},
+}
+
+// IsBindable returns true if the property of a component of the type can be bound to data
+func IsBindable(componentType, property string) bool {
+	for _, p := range commonBindings {
+		if p == property {
+			return true
+		}
+	}
+
+	for _, p := range typeBindings[componentType] {
+		if p == property {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (c *Component) getJsonOutput() ([]byte, error) {
 	copy := outputComponent{
-		Type:         c.Type,
-		Layer:        c.Layer,
-		Visible:      c.Visible,
-		Pos1:         c.Pos1,
-		Pos2:         c.Pos2,
-		CornerRadius: c.CornerRadius,
-		Radius:       c.Radius,
-		Style:        c.Style,
-		Mouse:        c.Mouse,
-		Font:         c.Font,
-		Text:         c.Text,
+		Type:          c.Type,
+		Layer:         c.Layer,
+		Visible:       c.Visible,
+		Pos1:          c.Pos1,
+		Pos2:          c.Pos2,
+		Pos3:          c.Pos3,
+		Pos4:          c.Pos4,
+		CornerRadius:  c.CornerRadius,
+		Radius:        c.Radius,
+		Style:         c.Style,
+		Mouse:         c.Mouse,
+		Font:          c.Font,
+		Text:          c.Text,
+		Url:           c.Url,
+		Dimensions:    c.Dimensions,
+		Sub:           c.Sub,
+		SubDimensions: c.SubDimensions,
 	}
 
 	addMouseInside := func(s string) {
@@ -312,14 +434,30 @@ func (c *Component) getJsonOutput() ([]byte, error) {
 			copy.Pos1 = v
 		case "pos2":
 			copy.Pos2 = &v
+		case "pos3":
+			copy.Pos3 = &v
+		case "pos4":
+			copy.Pos4 = &v
 		case "style":
 			copy.Style = &v
 		case "text":
 			copy.Text = &v
+		case "dimensions":
+			copy.Dimensions = &v
+		case "sub":
+			copy.Sub = &v
+		case "subDimensions":
+			copy.SubDimensions = &v
+		case "visible":
+			copy.Visible = v
+		case "hitable":
+			copy.Hitable = &v
 		case "mouse_inside":
 			addMouseInside(v)
 		case "mouse_click":
 			addMouseClick(v)
+		default:
+			return nil, fmt.Errorf("unsupported binding '%s' for %s", prop, c.Type)
 		}
 	}
 
@@ -58,6 +58,15 @@ func TestStroke(t *testing.T) {
 	assert.EqualValues(t, toDURgb(b), cd.Color.Blue)
 	assert.EqualValues(t, 1, cd.Color.Alpha)
 	assert.EqualValues(t, 3, cd.Distance)
+
+	// The initial stroke width is 1, and there is no stroke without a color
+	cd, err = StrokeFromStyle("stroke:#e02c9f")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cd.Distance)
+
+	cd, err = StrokeFromStyle("stroke:none;stroke-width:3")
+	assert.NoError(t, err)
+	assert.Nil(t, cd)
 }
 
 func TestStyleFromInlineCSS(t *testing.T) {
@@ -106,3 +115,84 @@ func TestSyleComparison(t *testing.T) {
 	}
 	assert.True(t, s1.Equals(&s2))
 }
+
+func TestComponentVisibility(t *testing.T) {
+	c := Component{Type: "box", Layer: 1}
+	b, err := c.getJsonOutput()
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"visible":false`)
+}
+
+func TestComponentBindings(t *testing.T) {
+	c := Component{
+		Type:    "image",
+		Layer:   1,
+		Visible: true,
+		Bindings: map[string]string{
+			"visible":       "$bool(path{a:shown}:init{true})",
+			"hitable":       "$bool(path{a:hit}:init{false})",
+			"sub":           "$vec2(path{a:sub}:init{(1,2)})",
+			"subDimensions": "$vec2(path{a:subDim}:init{(3,4)})",
+		},
+	}
+	b, err := c.getJsonOutput()
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"visible":"$bool(path{a:shown}:init{true})"`)
+	assert.Contains(t, string(b), `"hitable":"$bool(path{a:hit}:init{false})"`)
+	assert.Contains(t, string(b), `"sub":"$vec2(path{a:sub}:init{(1,2)})"`)
+	assert.Contains(t, string(b), `"subDimensions":"$vec2(path{a:subDim}:init{(3,4)})"`)
+
+	c.Bindings = map[string]string{"radius": "$num(path{a:r}:init{1})"}
+	_, err = c.getJsonOutput()
+	assert.ErrorContains(t, err, "unsupported binding 'radius' for image")
+}
+
+func TestIsBindable(t *testing.T) {
+	tests := []struct {
+		componentType string
+		property      string
+		expected      bool
+	}{
+		{"box", "visible", true},
+		{"box", "pos2", true},
+		{"box", "corner_radius", false},
+		{"text", "text", true},
+		{"text", "font", false},
+		{"line", "pos3", false},
+		{"circle", "radius", false},
+		{"quad", "pos4", true},
+		{"triangle", "pos4", false},
+		{"bezier", "pos3", true},
+		{"image", "subDimensions", true},
+		{"image", "url", false},
+		{"unknown", "mouse_click", true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, IsBindable(test.componentType, test.property), test.componentType+" "+test.property)
+	}
+}
+
+func TestAlignFromStyle(t *testing.T) {
+	tests := []struct {
+		style    string
+		expected string
+	}{
+		{"", "h0,v3"},
+		{"text-anchor:start", "h0,v3"},
+		{"text-anchor:middle;dominant-baseline:middle", "h1,v2"},
+		{"text-anchor:end;dominant-baseline:hanging", "h2,v1"},
+		{"dominant-baseline:text-before-edge", "h0,v0"},
+		{"dominant-baseline:ideographic", "h0,v4"},
+		{"dominant-baseline:text-after-edge", "h0,v5"},
+		{"dominant-baseline:central;alignment-baseline:hanging", "h0,v1"},
+		{"dominant-baseline:central;alignment-baseline:auto", "h0,v2"},
+		{"text-anchor:start;direction:rtl", "h2,v3"},
+		{"text-anchor:middle;direction:rtl", "h1,v3"},
+		{"text-anchor:bogus;dominant-baseline:bogus", "h0,v3"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, AlignFromStyle(test.style), test.style)
+	}
+}
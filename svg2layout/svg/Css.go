@@ -0,0 +1,433 @@
+package svg
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Declaration is a single CSS property and its value, such as "fill:#ffffff"
+type Declaration struct {
+	Property string
+	Value    string
+}
+
+type Declarations []Declaration
+
+// inheritedProperties are the properties an element gets from its parent when it doesn't set them itself.
+var inheritedProperties = map[string]bool{
+	"color":                        true,
+	"direction":                    true,
+	"dominant-baseline":            true,
+	"fill":                         true,
+	"fill-opacity":                 true,
+	"fill-rule":                    true,
+	"-du-fill-intensity":           true,
+	"-du-stroke-intensity":         true,
+	"font":                         true,
+	"font-family":                  true,
+	"font-size":                    true,
+	"font-stretch":                 true,
+	"font-style":                   true,
+	"font-variant":                 true,
+	"font-weight":                  true,
+	"-inkscape-font-specification": true,
+	"letter-spacing":               true,
+	"stroke":                       true,
+	"stroke-dasharray":             true,
+	"stroke-linecap":               true,
+	"stroke-linejoin":              true,
+	"stroke-opacity":               true,
+	"stroke-width":                 true,
+	"text-align":                   true,
+	"text-anchor":                  true,
+	"visibility":                   true,
+	"word-spacing":                 true,
+	"writing-mode":                 true,
+}
+
+// IsInherited returns true if the property is inherited by child elements. Custom properties are always inherited.
+func IsInherited(property string) bool {
+	return inheritedProperties[property] || strings.HasPrefix(property, "--")
+}
+
+// ParseDeclarations parses a list of declarations, such as the content of a style attribute.
+// When a property is declared more than once, the last one is kept.
+func ParseDeclarations(css string) (decl Declarations) {
+	for _, part := range strings.Split(css, ";") {
+		property, value, found := strings.Cut(part, ":")
+		property = strings.TrimSpace(property)
+		if !found || property == "" {
+			continue
+		}
+
+		decl = decl.With(property, strings.TrimSpace(value))
+	}
+
+	return
+}
+
+// Get returns the value of the property
+func (d Declarations) Get(property string) (value string, found bool) {
+	for _, v := range d {
+		if v.Property == property {
+			return v.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// With returns the declarations with the property set to the value, replacing any existing value.
+func (d Declarations) With(property, value string) Declarations {
+	for i, v := range d {
+		if v.Property == property {
+			res := append(Declarations{}, d...)
+			res[i].Value = value
+			return res
+		}
+	}
+
+	return append(d, Declaration{property, value})
+}
+
+// Merge returns the declarations extended with those in other that are not already declared.
+func (d Declarations) Merge(other Declarations) Declarations {
+	res := append(Declarations{}, d...)
+	for _, v := range other {
+		if _, found := res.Get(v.Property); !found {
+			res = append(res, v)
+		}
+	}
+
+	return res
+}
+
+// Inherit returns the declarations extended with the inherited properties of the parent
+// that are not already declared.
+func (d Declarations) Inherit(parent Declarations) Declarations {
+	inherited := Declarations{}
+	for _, v := range parent {
+		if IsInherited(v.Property) {
+			inherited = append(inherited, v)
+		}
+	}
+
+	return d.Merge(inherited)
+}
+
+// CustomProperties returns the custom properties, i.e. those starting with "--"
+func (d Declarations) CustomProperties() (custom Declarations) {
+	for _, v := range d {
+		if strings.HasPrefix(v.Property, "--") {
+			custom = append(custom, v)
+		}
+	}
+
+	return
+}
+
+// Resolve returns the declarations with var() references replaced by the value of the custom properties.
+// Custom properties in overrides take precedence over those in the declarations.
+func (d Declarations) Resolve(overrides Declarations) (res Declarations, err error) {
+	r := resolver{overrides: overrides, declarations: d}
+
+	res = make(Declarations, 0, len(d))
+	for _, v := range d {
+		value := v.Value
+		if o, found := overrides.Get(v.Property); found && strings.HasPrefix(v.Property, "--") {
+			value = o
+		}
+
+		if value, err = r.resolve(value, map[string]bool{}); err != nil {
+			err = fmt.Errorf("%s: %v", v.Property, err)
+			return
+		}
+		res = append(res, Declaration{v.Property, value})
+	}
+
+	return
+}
+
+type resolver struct {
+	overrides    Declarations
+	declarations Declarations
+}
+
+// resolve replaces the var() references in the value; visiting holds the custom properties being resolved
+// to detect circular references.
+func (r resolver) resolve(value string, visiting map[string]bool) (string, error) {
+	for {
+		start := strings.Index(value, "var(")
+		if start < 0 {
+			return value, nil
+		}
+
+		// Find the closing parenthesis and the first comma, which separates the fallback value
+		end := -1
+		comma := -1
+		depth := 0
+		for i := start + 3; i < len(value) && end < 0; i++ {
+			switch value[i] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+				if depth == 0 {
+					end = i
+				}
+			case ',':
+				if depth == 1 && comma < 0 {
+					comma = i
+				}
+			}
+		}
+
+		if end < 0 {
+			return "", fmt.Errorf("unterminated var() in '%s'", value)
+		}
+
+		name := value[start+4 : end]
+		fallback := ""
+		if comma >= 0 {
+			name = value[start+4 : comma]
+			fallback = strings.TrimSpace(value[comma+1 : end])
+		}
+		name = strings.TrimSpace(name)
+
+		v, found := r.overrides.Get(name)
+		if !found {
+			v, found = r.declarations.Get(name)
+		}
+
+		if found {
+			if visiting[name] {
+				return "", fmt.Errorf("circular reference to custom property '%s'", name)
+			}
+			visiting[name] = true
+		} else if comma >= 0 {
+			v = fallback
+		} else {
+			return "", fmt.Errorf("undefined custom property '%s'", name)
+		}
+
+		resolved, err := r.resolve(v, visiting)
+		if err != nil {
+			return "", err
+		}
+		delete(visiting, name)
+
+		value = value[:start] + resolved + value[end+1:]
+	}
+}
+
+func (d Declarations) String() string {
+	parts := make([]string, 0, len(d))
+	for _, v := range d {
+		parts = append(parts, v.Property+":"+v.Value)
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// Selector is a compound CSS selector, such as rect, #id, .a or rect.a.b
+type Selector struct {
+	// Element is the element name, empty for any element
+	Element string
+	Id      string
+	Classes []string
+}
+
+// Specificity orders selectors; ids weigh more than classes, which weigh more than element names.
+func (s Selector) Specificity() int {
+	specificity := len(s.Classes) * 100
+	if s.Id != "" {
+		specificity += 10000
+	}
+	if s.Element != "" {
+		specificity++
+	}
+	return specificity
+}
+
+// Matches returns true if the selector applies to the element
+func (s Selector) Matches(element, id string, classes []string) bool {
+	if s.Element != "" && s.Element != element {
+		return false
+	}
+
+	if s.Id != "" && s.Id != id {
+		return false
+	}
+
+	for _, class := range s.Classes {
+		found := false
+		for _, c := range classes {
+			found = found || c == class
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsClassOnly returns true if the selector is a single class, such as .a
+func (s Selector) IsClassOnly() bool {
+	return s.Element == "" && s.Id == "" && len(s.Classes) == 1
+}
+
+// Rule is a CSS rule, i.e. a group of selectors and the declarations that apply to the elements they match.
+type Rule struct {
+	Selectors    []Selector
+	Declarations Declarations
+}
+
+// StyleSheet holds the rules of style elements, in the order they are declared.
+type StyleSheet []Rule
+
+var (
+	cssCommentExp  = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssSelectorExp = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9_-]*|\*)?((?:[.#][a-zA-Z0-9_-]+)*)$`)
+	cssNameExp     = regexp.MustCompile(`[.#][a-zA-Z0-9_-]+`)
+)
+
+// ParseStyleSheet parses the content of a style element. Selectors that are not supported, such as those
+// with combinators, pseudo-classes or attributes, as well as at-rules, are returned as ignored.
+func ParseStyleSheet(css string) (sheet StyleSheet, ignored []string, err error) {
+	rest := cssCommentExp.ReplaceAllString(css, " ")
+
+	for strings.TrimSpace(rest) != "" {
+		open := strings.Index(rest, "{")
+		if open < 0 {
+			err = fmt.Errorf("expected '{' in style sheet near '%s'", strings.TrimSpace(rest))
+			return
+		}
+
+		// Find the matching brace, at-rules such as @media may contain nested blocks.
+		end := -1
+		depth := 0
+		for i := open; i < len(rest) && end < 0; i++ {
+			switch rest[i] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					end = i
+				}
+			}
+		}
+
+		if end < 0 {
+			err = fmt.Errorf("unterminated block in style sheet near '%s'", strings.TrimSpace(rest[:open]))
+			return
+		}
+
+		prelude := strings.TrimSpace(rest[:open])
+		body := rest[open+1 : end]
+		rest = rest[end+1:]
+
+		if strings.HasPrefix(prelude, "@") {
+			ignored = append(ignored, prelude)
+			continue
+		}
+
+		rule := Rule{Declarations: ParseDeclarations(body)}
+		for _, text := range strings.Split(prelude, ",") {
+			if selector, ok := parseSelector(strings.TrimSpace(text)); ok {
+				rule.Selectors = append(rule.Selectors, selector)
+			} else {
+				ignored = append(ignored, strings.TrimSpace(text))
+			}
+		}
+
+		if len(rule.Selectors) > 0 {
+			sheet = append(sheet, rule)
+		}
+	}
+
+	return
+}
+
+func parseSelector(text string) (selector Selector, ok bool) {
+	// The root element of an SVG is the svg element
+	if text == ":root" {
+		return Selector{Element: "svg"}, true
+	}
+
+	m := cssSelectorExp.FindStringSubmatch(text)
+	if text == "" || m == nil {
+		return
+	}
+
+	if m[1] != "*" {
+		selector.Element = m[1]
+	}
+
+	for _, name := range cssNameExp.FindAllString(m[2], -1) {
+		if name[0] == '#' {
+			if selector.Id != "" && selector.Id != name[1:] {
+				// An element can't have two ids
+				return
+			}
+			selector.Id = name[1:]
+		} else {
+			selector.Classes = append(selector.Classes, name[1:])
+		}
+	}
+
+	return selector, true
+}
+
+// Match returns the declarations of the rules matching the element. When several rules declare the same
+// property, the one with the most specific selector wins, or the last one if they are equally specific.
+func (s StyleSheet) Match(element, id string, classes []string) (decl Declarations) {
+	type match struct {
+		specificity int
+		rule        Rule
+	}
+
+	var matches []match
+	for _, rule := range s {
+		specificity := -1
+		for _, selector := range rule.Selectors {
+			if selector.Matches(element, id, classes) && selector.Specificity() > specificity {
+				specificity = selector.Specificity()
+			}
+		}
+
+		if specificity >= 0 {
+			matches = append(matches, match{specificity, rule})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].specificity < matches[j].specificity
+	})
+
+	for _, m := range matches {
+		for _, d := range m.rule.Declarations {
+			decl = decl.With(d.Property, d.Value)
+		}
+	}
+
+	return
+}
+
+// DefinesClass returns true if any selector in the style sheet uses the class
+func (s StyleSheet) DefinesClass(class string) bool {
+	for _, rule := range s {
+		for _, selector := range rule.Selectors {
+			for _, c := range selector.Classes {
+				if c == class {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
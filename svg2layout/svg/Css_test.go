@@ -0,0 +1,113 @@
+package svg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDeclarations(t *testing.T) {
+	d := ParseDeclarations(" fill : #ffffff ;stroke:none;; invalid ;fill:#000000")
+	assert.Equal(t, Declarations{{"fill", "#000000"}, {"stroke", "none"}}, d)
+
+	v, found := d.Get("stroke")
+	assert.True(t, found)
+	assert.Equal(t, "none", v)
+	_, found = d.Get("opacity")
+	assert.False(t, found)
+
+	assert.Equal(t, "fill:#000000;stroke:none", d.String())
+}
+
+func TestMergeAndInheritDeclarations(t *testing.T) {
+	child := ParseDeclarations("fill:#ffffff")
+	class := ParseDeclarations("fill:#000000;stroke-width:2")
+	parent := ParseDeclarations("stroke:#ff0000;opacity:0.5;transform:none;--color:red")
+
+	merged := child.Merge(class)
+	assert.Equal(t, "fill:#ffffff;stroke-width:2", merged.String())
+
+	// Opacity and transform are not inherited
+	assert.Equal(t, "fill:#ffffff;stroke-width:2;stroke:#ff0000;--color:red", merged.Inherit(parent).String())
+
+	// The original is not modified
+	assert.Equal(t, "fill:#ffffff", child.String())
+}
+
+func TestParseStyleSheet(t *testing.T) {
+	css := `
+	/* A comment with a rule inside: .x { fill: red } */
+	rect { fill: #000000 }
+	.a, .b { stroke: #ffffff; }
+	rect.a.b#c { fill: #ff0000 }
+	g > rect { fill: #00ff00 }
+	@media screen { .a { fill: #0000ff } }
+	`
+
+	sheet, ignored, err := ParseStyleSheet(css)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"g > rect", "@media screen"}, ignored)
+	assert.Equal(t, 3, len(sheet))
+
+	assert.Equal(t, []Selector{{Element: "rect"}}, sheet[0].Selectors)
+	assert.Equal(t, []Selector{{Classes: []string{"a"}}, {Classes: []string{"b"}}}, sheet[1].Selectors)
+	assert.Equal(t, []Selector{{Element: "rect", Id: "c", Classes: []string{"a", "b"}}}, sheet[2].Selectors)
+	assert.True(t, sheet[1].Selectors[0].IsClassOnly())
+	assert.False(t, sheet[2].Selectors[0].IsClassOnly())
+
+	assert.True(t, sheet.DefinesClass("b"))
+	assert.False(t, sheet.DefinesClass("x"))
+
+	_, _, err = ParseStyleSheet(".a { fill: red")
+	assert.Error(t, err)
+}
+
+func TestStyleSheetMatch(t *testing.T) {
+	sheet, _, err := ParseStyleSheet(`
+	#id { fill: #0000ff }
+	.a.b { fill: #00ff00; stroke: #000000 }
+	.a { fill: #ff0000; stroke-width: 2 }
+	rect { fill: #ffffff; stroke: #ffffff; opacity: 0.5 }
+	* { fill-opacity: 0.5 }
+	`)
+	assert.NoError(t, err)
+
+	// Most specific selector wins regardless of order
+	assert.Equal(t, "fill-opacity:0.5;fill:#ffffff;stroke:#ffffff;opacity:0.5", sheet.Match("rect", "", nil).String())
+	assert.Equal(t, "fill-opacity:0.5;fill:#ff0000;stroke:#ffffff;opacity:0.5;stroke-width:2", sheet.Match("rect", "", []string{"a"}).String())
+
+	decl := sheet.Match("rect", "id", []string{"a", "b"})
+	v, _ := decl.Get("fill")
+	assert.Equal(t, "#0000ff", v)
+	v, _ = decl.Get("stroke")
+	assert.Equal(t, "#000000", v)
+
+	// Only the universal selector matches
+	assert.Equal(t, "fill-opacity:0.5", sheet.Match("circle", "", []string{"b"}).String())
+}
+
+func TestResolveDeclarations(t *testing.T) {
+	d := ParseDeclarations("--accent:#ff0000;--border:var(--accent);fill:var(--accent);stroke:var(--border);color:var(--missing, rgb(0, 0, var(--blue, 255)))")
+
+	res, err := d.Resolve(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "--accent:#ff0000;--border:#ff0000;fill:#ff0000;stroke:#ff0000;color:rgb(0, 0, 255)", res.String())
+
+	// Overrides take precedence, also for properties referenced indirectly
+	res, err = d.Resolve(ParseDeclarations("--accent:#00ff00;--blue:128"))
+	assert.NoError(t, err)
+	assert.Equal(t, "--accent:#00ff00;--border:#00ff00;fill:#00ff00;stroke:#00ff00;color:rgb(0, 0, 128)", res.String())
+
+	_, err = ParseDeclarations("fill:var(--missing)").Resolve(nil)
+	assert.ErrorContains(t, err, "undefined custom property '--missing'")
+
+	_, err = ParseDeclarations("--a:var(--b);--b:var(--a);fill:var(--a)").Resolve(nil)
+	assert.ErrorContains(t, err, "circular")
+
+	_, err = ParseDeclarations("fill:var(--a").Resolve(nil)
+	assert.Error(t, err)
+
+	sheet, _, err := ParseStyleSheet(":root { --a: 1 }")
+	assert.NoError(t, err)
+	assert.Equal(t, "--a:1", sheet.Match("svg", "", nil).String())
+}
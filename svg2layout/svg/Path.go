@@ -0,0 +1,374 @@
+package svg
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type Point struct {
+	X float64
+	Y float64
+}
+
+type SegmentKind int
+
+const (
+	LineSegment SegmentKind = iota
+	QuadraticSegment
+	CubicSegment
+	ArcSegment
+)
+
+// Arc holds the parameters of an elliptical arc segment
+type Arc struct {
+	Rx       float64
+	Ry       float64
+	Rotation float64
+	LargeArc bool
+	Sweep    bool
+}
+
+// Segment is a single, absolute, piece of a path.
+type Segment struct {
+	Kind  SegmentKind
+	Start Point
+	End   Point
+	// Control1 is used by quadratic and cubic segments, Control2 only by cubic segments.
+	Control1 Point
+	Control2 Point
+	Arc      Arc
+}
+
+// SubPath is a sequence of connected segments, started by a move-to command.
+type SubPath struct {
+	Segments []Segment
+	Closed   bool
+}
+
+// Start returns the starting point of the sub path
+func (s *SubPath) Start() Point {
+	return s.Segments[0].Start
+}
+
+// Points returns the start point of the sub path followed by the end point of each segment.
+func (s *SubPath) Points() []Point {
+	if len(s.Segments) == 0 {
+		return nil
+	}
+
+	points := []Point{s.Start()}
+	for _, seg := range s.Segments {
+		points = append(points, seg.End)
+	}
+	return points
+}
+
+// numberScanner splits SVG number lists, such as path data and polyline points, into their parts.
+type numberScanner struct {
+	data string
+	pos  int
+}
+
+func (s *numberScanner) skipSeparators() {
+	for s.pos < len(s.data) && (unicode.IsSpace(rune(s.data[s.pos])) || s.data[s.pos] == ',') {
+		s.pos++
+	}
+}
+
+func (s *numberScanner) done() bool {
+	s.skipSeparators()
+	return s.pos >= len(s.data)
+}
+
+// peekCommand returns the command letter at the current position, if there is one.
+func (s *numberScanner) peekCommand() (byte, bool) {
+	if s.done() {
+		return 0, false
+	}
+
+	c := s.data[s.pos]
+	if (c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') && c != 'e' && c != 'E' {
+		return c, true
+	}
+
+	return 0, false
+}
+
+func (s *numberScanner) number() (f float64, err error) {
+	s.skipSeparators()
+	start := s.pos
+
+	if s.pos < len(s.data) && (s.data[s.pos] == '-' || s.data[s.pos] == '+') {
+		s.pos++
+	}
+
+	dot := false
+	digits := false
+	for s.pos < len(s.data) {
+		c := s.data[s.pos]
+		if c >= '0' && c <= '9' {
+			digits = true
+		} else if c == '.' && !dot {
+			dot = true
+		} else {
+			break
+		}
+		s.pos++
+	}
+
+	if digits && s.pos < len(s.data) && (s.data[s.pos] == 'e' || s.data[s.pos] == 'E') {
+		exp := s.pos
+		s.pos++
+		if s.pos < len(s.data) && (s.data[s.pos] == '-' || s.data[s.pos] == '+') {
+			s.pos++
+		}
+		expDigits := false
+		for s.pos < len(s.data) && s.data[s.pos] >= '0' && s.data[s.pos] <= '9' {
+			s.pos++
+			expDigits = true
+		}
+		if !expDigits {
+			s.pos = exp
+		}
+	}
+
+	if !digits {
+		err = fmt.Errorf("expected number at position %d in '%s'", start, s.data)
+		return
+	}
+
+	return strconv.ParseFloat(s.data[start:s.pos], 64)
+}
+
+// ParseNumbers parses a list of numbers separated by white space and/or commas.
+func ParseNumbers(data string) (numbers []float64, err error) {
+	s := &numberScanner{data: data}
+	for !s.done() {
+		var f float64
+		if f, err = s.number(); err != nil {
+			return
+		}
+		numbers = append(numbers, f)
+	}
+	return
+}
+
+// ParsePoints parses the points attribute of polylines and polygons
+func ParsePoints(data string) (points []Point, err error) {
+	var numbers []float64
+	if numbers, err = ParseNumbers(data); err != nil {
+		return
+	}
+
+	if len(numbers)%2 != 0 {
+		err = fmt.Errorf("odd number of coordinates in points '%s'", data)
+		return
+	}
+
+	for i := 0; i < len(numbers); i += 2 {
+		points = append(points, Point{numbers[i], numbers[i+1]})
+	}
+
+	return
+}
+
+// ParsePathData parses the 'd' attribute of a path into sub paths with absolute coordinates.
+func ParsePathData(data string) (paths []SubPath, err error) {
+	s := &numberScanner{data: strings.TrimSpace(data)}
+
+	var (
+		curr    = -1 // Index of the current sub path
+		pos     Point
+		start   Point
+		command byte
+		// Control point of the previous segment, used by the smooth curve commands
+		lastControl Point
+		lastKind    = LineSegment
+	)
+
+	add := func(seg Segment) {
+		if curr < 0 {
+			paths = append(paths, SubPath{})
+			curr = len(paths) - 1
+		}
+		seg.Start = pos
+		paths[curr].Segments = append(paths[curr].Segments, seg)
+		pos = seg.End
+		lastKind = seg.Kind
+	}
+
+	// reflect returns the reflection of the previous control point, if the previous segment was of the same kind
+	reflect := func(kind SegmentKind) Point {
+		if lastKind == kind {
+			return Point{2*pos.X - lastControl.X, 2*pos.Y - lastControl.Y}
+		}
+		return pos
+	}
+
+	for !s.done() {
+		if c, ok := s.peekCommand(); ok {
+			command = c
+			s.pos++
+		} else if command == 0 {
+			err = fmt.Errorf("expected a command at position %d in '%s'", s.pos, data)
+			return
+		}
+
+		relative := unicode.IsLower(rune(command))
+		var base Point
+		if relative {
+			base = pos
+		}
+
+		switch unicode.ToUpper(rune(command)) {
+		case 'M':
+			var p Point
+			if p, err = s.point(base); err != nil {
+				return
+			}
+			paths = append(paths, SubPath{})
+			curr = len(paths) - 1
+			pos = p
+			start = p
+			lastKind = LineSegment
+			// Subsequent coordinate pairs are implicit line-to commands
+			if relative {
+				command = 'l'
+			} else {
+				command = 'L'
+			}
+		case 'L':
+			var p Point
+			if p, err = s.point(base); err != nil {
+				return
+			}
+			add(Segment{Kind: LineSegment, End: p})
+		case 'H':
+			var x float64
+			if x, err = s.number(); err != nil {
+				return
+			}
+			add(Segment{Kind: LineSegment, End: Point{base.X + x, pos.Y}})
+		case 'V':
+			var y float64
+			if y, err = s.number(); err != nil {
+				return
+			}
+			add(Segment{Kind: LineSegment, End: Point{pos.X, base.Y + y}})
+		case 'C', 'S':
+			seg := Segment{Kind: CubicSegment}
+			if unicode.ToUpper(rune(command)) == 'C' {
+				if seg.Control1, err = s.point(base); err != nil {
+					return
+				}
+			} else {
+				seg.Control1 = reflect(CubicSegment)
+			}
+			if seg.Control2, err = s.point(base); err != nil {
+				return
+			}
+			if seg.End, err = s.point(base); err != nil {
+				return
+			}
+			lastControl = seg.Control2
+			add(seg)
+		case 'Q', 'T':
+			seg := Segment{Kind: QuadraticSegment}
+			if unicode.ToUpper(rune(command)) == 'Q' {
+				if seg.Control1, err = s.point(base); err != nil {
+					return
+				}
+			} else {
+				seg.Control1 = reflect(QuadraticSegment)
+			}
+			if seg.End, err = s.point(base); err != nil {
+				return
+			}
+			lastControl = seg.Control1
+			add(seg)
+		case 'A':
+			seg := Segment{Kind: ArcSegment}
+			if seg.Arc.Rx, err = s.number(); err != nil {
+				return
+			}
+			if seg.Arc.Ry, err = s.number(); err != nil {
+				return
+			}
+			if seg.Arc.Rotation, err = s.number(); err != nil {
+				return
+			}
+			if seg.Arc.LargeArc, err = s.flag(); err != nil {
+				return
+			}
+			if seg.Arc.Sweep, err = s.flag(); err != nil {
+				return
+			}
+			if seg.End, err = s.point(base); err != nil {
+				return
+			}
+			seg.Arc.Rx = math.Abs(seg.Arc.Rx)
+			seg.Arc.Ry = math.Abs(seg.Arc.Ry)
+			// An arc without radius is a straight line
+			if seg.Arc.Rx == 0 || seg.Arc.Ry == 0 {
+				seg.Kind = LineSegment
+			}
+			add(seg)
+		case 'Z':
+			if curr >= 0 {
+				if pos != start {
+					add(Segment{Kind: LineSegment, End: start})
+				}
+				paths[curr].Closed = true
+				curr = -1
+			}
+			pos = start
+			lastKind = LineSegment
+			// Z takes no parameters, a following number is an error
+			command = 0
+		default:
+			err = fmt.Errorf("unsupported path command '%c' in '%s'", command, data)
+			return
+		}
+	}
+
+	// Remove sub paths that only consist of a move-to
+	res := paths[:0]
+	for _, p := range paths {
+		if len(p.Segments) > 0 {
+			res = append(res, p)
+		}
+	}
+	paths = res
+
+	return
+}
+
+// flag reads an arc flag, which may be written without separators to the next value.
+func (s *numberScanner) flag() (f bool, err error) {
+	s.skipSeparators()
+	if s.pos < len(s.data) && (s.data[s.pos] == '0' || s.data[s.pos] == '1') {
+		f = s.data[s.pos] == '1'
+		s.pos++
+		return
+	}
+
+	err = fmt.Errorf("expected flag at position %d in '%s'", s.pos, s.data)
+	return
+}
+
+func (s *numberScanner) point(base Point) (p Point, err error) {
+	if p.X, err = s.number(); err != nil {
+		return
+	}
+
+	if p.Y, err = s.number(); err != nil {
+		return
+	}
+
+	p.X += base.X
+	p.Y += base.Y
+	return
+}
@@ -0,0 +1,84 @@
+package svg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNumbers(t *testing.T) {
+	n, err := ParseNumbers("1,2 -3.5-4 .5.5 1e2,-1.5E-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1, 2, -3.5, -4, 0.5, 0.5, 100, -0.15}, n)
+
+	_, err = ParseNumbers("1,a")
+	assert.Error(t, err)
+}
+
+func TestParsePoints(t *testing.T) {
+	p, err := ParsePoints("10,20 30,40")
+	assert.NoError(t, err)
+	assert.Equal(t, []Point{{10, 20}, {30, 40}}, p)
+
+	_, err = ParsePoints("10,20 30")
+	assert.Error(t, err)
+}
+
+func TestParsePathDataLines(t *testing.T) {
+	paths, err := ParsePathData("M 10,10 L 20,10 h 10 v 10 H 10 z m 5,5 l 1,1")
+	assert.NoError(t, err)
+	assert.Len(t, paths, 2)
+	assert.True(t, paths[0].Closed)
+	assert.Equal(t, []Point{{10, 10}, {20, 10}, {30, 10}, {30, 20}, {10, 20}, {10, 10}}, paths[0].Points())
+	assert.False(t, paths[1].Closed)
+	assert.Equal(t, []Point{{15, 15}, {16, 16}}, paths[1].Points())
+
+	// Implicit line-to after move-to
+	paths, err = ParsePathData("m 1,1 2,2 3,3")
+	assert.NoError(t, err)
+	assert.Equal(t, []Point{{1, 1}, {3, 3}, {6, 6}}, paths[0].Points())
+
+	_, err = ParsePathData("10,10 L 20,20")
+	assert.Error(t, err)
+}
+
+func TestParsePathDataCurves(t *testing.T) {
+	paths, err := ParsePathData("M 0,0 C 1,1 2,1 3,0 S 5,-1 6,0 Q 7,1 8,0 T 10,0 A 5 5 0 0 1 20,0 a5,5 0 1020,0")
+	assert.NoError(t, err)
+	assert.Len(t, paths, 1)
+	s := paths[0].Segments
+	assert.Len(t, s, 6)
+
+	assert.Equal(t, CubicSegment, s[0].Kind)
+	assert.Equal(t, Point{1, 1}, s[0].Control1)
+	assert.Equal(t, Point{2, 1}, s[0].Control2)
+	assert.Equal(t, Point{3, 0}, s[0].End)
+
+	// First control point is the reflection of the previous second control point
+	assert.Equal(t, CubicSegment, s[1].Kind)
+	assert.Equal(t, Point{4, -1}, s[1].Control1)
+	assert.Equal(t, Point{5, -1}, s[1].Control2)
+
+	assert.Equal(t, QuadraticSegment, s[2].Kind)
+	assert.Equal(t, Point{7, 1}, s[2].Control1)
+	assert.Equal(t, QuadraticSegment, s[3].Kind)
+	assert.Equal(t, Point{9, -1}, s[3].Control1)
+	assert.Equal(t, Point{10, 0}, s[3].End)
+
+	assert.Equal(t, ArcSegment, s[4].Kind)
+	assert.Equal(t, Arc{Rx: 5, Ry: 5, Rotation: 0, LargeArc: false, Sweep: true}, s[4].Arc)
+	assert.Equal(t, Point{20, 0}, s[4].End)
+
+	// Flags written without separators
+	assert.Equal(t, ArcSegment, s[5].Kind)
+	assert.Equal(t, Arc{Rx: 5, Ry: 5, Rotation: 0, LargeArc: true, Sweep: false}, s[5].Arc)
+	assert.Equal(t, Point{40, 0}, s[5].End)
+
+	// Smooth curves without a preceding curve use the current point as control point
+	paths, err = ParsePathData("M 1,1 T 3,3")
+	assert.NoError(t, err)
+	assert.Equal(t, Point{1, 1}, paths[0].Segments[0].Control1)
+
+	_, err = ParsePathData("M 0,0 A 5 5 0 2 1 20,0")
+	assert.Error(t, err)
+}
@@ -3,6 +3,8 @@ package svg
 import (
 	"encoding/xml"
 	"fmt"
+	"io"
+	"regexp"
 	"strings"
 )
 
@@ -19,11 +21,32 @@ type PathEffect struct {
 	Radius  float64  `xml:"radius,attr"`
 }
 
+// FilterPrimitive is one of the fe* elements of a filter, holding the attributes of all supported primitives.
+type FilterPrimitive struct {
+	XMLName      xml.Name
+	In           string `xml:"in,attr"`
+	In2          string `xml:"in2,attr"`
+	Result       string `xml:"result,attr"`
+	StdDeviation string `xml:"stdDeviation,attr"`
+	Dx           string `xml:"dx,attr"`
+	Dy           string `xml:"dy,attr"`
+	FloodColor   string `xml:"flood-color,attr"`
+	FloodOpacity string `xml:"flood-opacity,attr"`
+	Style        string `xml:"style,attr"`
+}
+
+type Filter struct {
+	XMLName    xml.Name          `xml:"filter"`
+	Id         string            `xml:"id,attr"`
+	Primitives []FilterPrimitive `xml:",any"`
+}
+
 type Defs struct {
 	XMLName    xml.Name     `xml:"defs"`
 	Id         string       `xml:"id,attr"`
 	Style      []Style      `xml:"style"`
 	PathEffect []PathEffect `xml:"path-effect"`
+	Filter     []Filter     `xml:"filter"`
 }
 
 type Description struct {
@@ -36,9 +59,80 @@ type MixedShape struct {
 	Value interface{}
 }
 
+// StyledShape holds what all elements have in common; their id, style, description and presentation attributes.
 type StyledShape struct {
-	Style string `xml:"style,attr"`
-	Class string `xml:"class,attr"`
+	Description Description
+	Id          string `xml:"id,attr"`
+	Style       string `xml:"style,attr"`
+	Class       string `xml:"class,attr"`
+	Display     string `xml:"display,attr"`
+	Visibility  string `xml:"visibility,attr"`
+	Opacity     string `xml:"opacity,attr"`
+	Filter      string `xml:"filter,attr"`
+	// Attrs holds the attributes not otherwise decoded, such as data-* attributes
+	Attrs []xml.Attr `xml:",any,attr"`
+}
+
+// DataAttributes returns the values of the data-* attributes by their name without the prefix
+func (s *StyledShape) DataAttributes() map[string]string {
+	data := map[string]string{}
+	for _, attr := range s.Attrs {
+		if attr.Name.Space == "" && strings.HasPrefix(attr.Name.Local, "data-") {
+			data[strings.TrimPrefix(attr.Name.Local, "data-")] = attr.Value
+		}
+	}
+	return data
+}
+
+// presentationAttributes are the attributes that set a style property of the same name.
+var presentationAttributes = map[string]bool{
+	"alignment-baseline": true,
+	"color":              true,
+	"direction":          true,
+	"dominant-baseline":  true,
+	"fill":               true,
+	"fill-opacity":       true,
+	"fill-rule":          true,
+	"font-family":        true,
+	"font-size":          true,
+	"font-stretch":       true,
+	"font-style":         true,
+	"font-variant":       true,
+	"font-weight":        true,
+	"letter-spacing":     true,
+	"stroke":             true,
+	"stroke-dasharray":   true,
+	"stroke-linecap":     true,
+	"stroke-linejoin":    true,
+	"stroke-opacity":     true,
+	"stroke-width":       true,
+	"text-anchor":        true,
+	"word-spacing":       true,
+	"writing-mode":       true,
+}
+
+// PresentationAttributes returns the style properties set by attributes, such as fill="red", in order of appearance.
+// These have lower priority than the style attribute and style sheet rules.
+func (s *StyledShape) PresentationAttributes() (decl Declarations) {
+	for _, attr := range s.Attrs {
+		if attr.Name.Space == "" && presentationAttributes[attr.Name.Local] {
+			decl = decl.With(attr.Name.Local, strings.TrimSpace(attr.Value))
+		}
+	}
+
+	if s.Visibility != "" {
+		decl = decl.With("visibility", s.Visibility)
+	}
+
+	if s.Filter != "" {
+		decl = decl.With("filter", s.Filter)
+	}
+
+	return
+}
+
+type TransformedShape struct {
+	Transform string `xml:"transform,attr"`
 }
 
 type PositionalShape struct {
@@ -52,34 +146,211 @@ type ShapeArea struct {
 	Height float64 `xml:"height,attr"`
 }
 
+// TextPosition holds the positioning attributes of text and tspan elements. Each of them may be a list
+// with one value per character, of which only the first one is used.
+type TextPosition struct {
+	X  string `xml:"x,attr"`
+	Y  string `xml:"y,attr"`
+	Dx string `xml:"dx,attr"`
+	Dy string `xml:"dy,attr"`
+}
+
+// Move returns the position of the text following the attributes, starting at the current text position.
+// hasX is true when the horizontal position is given by the x attribute rather than following previous text.
+func (p TextPosition) Move(current Point) (moved Point, hasX bool, err error) {
+	moved = current
+
+	var v float64
+	var found bool
+	if v, found, err = firstNumber(p.X); err != nil {
+		return
+	} else if found {
+		moved.X = v
+		hasX = true
+	}
+
+	if v, found, err = firstNumber(p.Y); err != nil {
+		return
+	} else if found {
+		moved.Y = v
+	}
+
+	if v, _, err = firstNumber(p.Dx); err != nil {
+		return
+	}
+	moved.X += v
+
+	if v, _, err = firstNumber(p.Dy); err != nil {
+		return
+	}
+	moved.Y += v
+
+	return
+}
+
+func firstNumber(list string) (f float64, found bool, err error) {
+	var numbers []float64
+	if numbers, err = ParseNumbers(list); err != nil || len(numbers) == 0 {
+		return
+	}
+
+	return numbers[0], true, nil
+}
+
+// TextContent is a part of the content of a text element, either character data or a tspan.
+type TextContent struct {
+	Text string
+	Span *TSpan
+}
+
 type TSpan struct {
-	PositionalShape
-	Text        string `xml:",cdata"`
-	Description Description
-	Span        []TSpan `xml:"tspan"`
+	TextPosition
+	// Space is the xml:space attribute, "preserve" keeps white space as is.
+	Space string `xml:"http://www.w3.org/XML/1998/namespace space,attr"`
+	// Inner holds the raw content, which is parsed by Content to keep character data and spans in order.
+	Inner string `xml:",innerxml"`
 	StyledShape
 }
 
+// Content returns the character data and nested spans of the span in document order.
+func (t *TSpan) Content() ([]TextContent, error) {
+	return parseTextContent(t.Inner)
+}
+
 type Text struct {
-	PositionalShape
-	Span        []TSpan `xml:"tspan"`
-	Description Description
+	TextPosition
+	// Space is the xml:space attribute, "preserve" keeps white space as is.
+	Space string `xml:"http://www.w3.org/XML/1998/namespace space,attr"`
+	// Inner holds the raw content, which is parsed by Content to keep character data and spans in order.
+	Inner string `xml:",innerxml"`
 	StyledShape
+	TransformedShape
+}
+
+// Content returns the character data and spans of the text in document order.
+func (t *Text) Content() ([]TextContent, error) {
+	return parseTextContent(t.Inner)
+}
+
+var whiteSpaceExp = regexp.MustCompile(`[ \t\r\n]+`)
+
+// NormalizeSpace converts new lines and tabs into spaces. Unless white space is preserved, consecutive
+// white space is also collapsed into a single space.
+func NormalizeSpace(text string, preserve bool) string {
+	if preserve {
+		return strings.NewReplacer("\r", " ", "\n", " ", "\t", " ").Replace(text)
+	}
+
+	return whiteSpaceExp.ReplaceAllString(text, " ")
+}
+
+// parseTextContent splits the content of a text or tspan element into character data and spans.
+// Other elements, such as desc and title, are not part of the rendered text and are skipped.
+func parseTextContent(inner string) (content []TextContent, err error) {
+	d := xml.NewDecoder(strings.NewReader(inner))
+
+	for {
+		var token xml.Token
+		if token, err = d.Token(); err == io.EOF {
+			return content, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.CharData:
+			// Adjacent character data, such as text around a CDATA section, is a single piece of text.
+			if n := len(content); n > 0 && content[n-1].Span == nil {
+				content[n-1].Text += string(t)
+			} else {
+				content = append(content, TextContent{Text: string(t)})
+			}
+		case xml.StartElement:
+			if t.Name.Local != "tspan" {
+				if err = d.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			span := &TSpan{}
+			if err = d.DecodeElement(span, &t); err != nil {
+				return nil, err
+			}
+			content = append(content, TextContent{Span: span})
+		}
+	}
 }
 
 type Rect struct {
 	ShapeArea
-	Description Description
-	PathEffect  string `xml:"path-effect"`
+	Rx         *float64 `xml:"rx,attr"`
+	Ry         *float64 `xml:"ry,attr"`
+	PathEffect string   `xml:"path-effect,attr"`
 	StyledShape
+	TransformedShape
 }
 
 type Circle struct {
-	X           float64 `xml:"cx,attr"`
-	Y           float64 `xml:"cy,attr"`
-	Radius      float64 `xml:"r,attr"`
-	Description Description
+	X      float64 `xml:"cx,attr"`
+	Y      float64 `xml:"cy,attr"`
+	Radius float64 `xml:"r,attr"`
 	StyledShape
+	TransformedShape
+}
+
+type Ellipse struct {
+	X  float64 `xml:"cx,attr"`
+	Y  float64 `xml:"cy,attr"`
+	Rx float64 `xml:"rx,attr"`
+	Ry float64 `xml:"ry,attr"`
+	StyledShape
+	TransformedShape
+}
+
+type Line struct {
+	X1 float64 `xml:"x1,attr"`
+	Y1 float64 `xml:"y1,attr"`
+	X2 float64 `xml:"x2,attr"`
+	Y2 float64 `xml:"y2,attr"`
+	StyledShape
+	TransformedShape
+}
+
+type Polyline struct {
+	Points string `xml:"points,attr"`
+	StyledShape
+	TransformedShape
+}
+
+type Polygon struct {
+	Points string `xml:"points,attr"`
+	StyledShape
+	TransformedShape
+}
+
+type Path struct {
+	D string `xml:"d,attr"`
+	StyledShape
+	TransformedShape
+}
+
+type Image struct {
+	ShapeArea
+	// xlink:href must be matched before href as the latter also matches namespaced attributes.
+	XlinkHref           string `xml:"http://www.w3.org/1999/xlink href,attr"`
+	Href                string `xml:"href,attr"`
+	PreserveAspectRatio string `xml:"preserveAspectRatio,attr"`
+	StyledShape
+	TransformedShape
+}
+
+// Url returns the referenced image, href takes precedence over the deprecated xlink:href.
+func (i *Image) Url() string {
+	if i.Href != "" {
+		return i.Href
+	}
+	return i.XlinkHref
 }
 
 func (m *MixedShape) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
@@ -105,6 +376,55 @@ func (m *MixedShape) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error
 		}
 		m.Value = e
 		m.Type = start.Name.Local
+	case "ellipse":
+		var e Ellipse
+		if err := d.DecodeElement(&e, &start); err != nil {
+			return err
+		}
+		m.Value = e
+		m.Type = start.Name.Local
+	case "line":
+		var e Line
+		if err := d.DecodeElement(&e, &start); err != nil {
+			return err
+		}
+		m.Value = e
+		m.Type = start.Name.Local
+	case "polyline":
+		var e Polyline
+		if err := d.DecodeElement(&e, &start); err != nil {
+			return err
+		}
+		m.Value = e
+		m.Type = start.Name.Local
+	case "g":
+		var e G
+		if err := d.DecodeElement(&e, &start); err != nil {
+			return err
+		}
+		m.Value = e
+		m.Type = start.Name.Local
+	case "image":
+		var e Image
+		if err := d.DecodeElement(&e, &start); err != nil {
+			return err
+		}
+		m.Value = e
+		m.Type = start.Name.Local
+	case "polygon":
+		var e Polygon
+		if err := d.DecodeElement(&e, &start); err != nil {
+			return err
+		}
+		m.Value = e
+		m.Type = start.Name.Local
+	case "path":
+		var e Path
+		if err := d.DecodeElement(&e, &start); err != nil {
+			return err
+		}
+		m.Value = e
+		m.Type = start.Name.Local
 	default:
 		return fmt.Errorf("unsupported element: %s", start)
 	}
@@ -112,8 +432,13 @@ func (m *MixedShape) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error
 }
 
 type G struct {
-	XMLName xml.Name     `xml:"g"`
-	Shape   []MixedShape `xml:",any"`
+	XMLName   xml.Name     `xml:"g"`
+	Label     string       `xml:"label,attr"`
+	GroupMode string       `xml:"groupmode,attr"`
+	Title     string       `xml:"title"`
+	Shape     []MixedShape `xml:",any"`
+	StyledShape
+	TransformedShape
 }
 
 type Svg struct {
@@ -122,6 +447,17 @@ type Svg struct {
 	Height  float64  `xml:"height,attr"`
 	Defs    Defs     `xml:"defs"`
 	Layer   []G      `xml:"g"`
+	StyledShape
+}
+
+func (svg *Svg) GetFilterById(id string) (Filter, bool) {
+	for _, v := range svg.Defs.Filter {
+		if v.Id == strings.Trim(id, "#") {
+			return v, true
+		}
+	}
+
+	return Filter{}, false
 }
 
 func (svg *Svg) GetCornerRadiusById(id string) (float64, bool) {
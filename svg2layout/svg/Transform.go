@@ -0,0 +1,203 @@
+package svg
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Matrix is an affine transformation matrix as used by the SVG transform attribute:
+//
+//	| A C E |
+//	| B D F |
+//	| 0 0 1 |
+type Matrix struct {
+	A, B, C, D, E, F float64
+}
+
+func Identity() Matrix {
+	return Matrix{A: 1, D: 1}
+}
+
+func Translate(x, y float64) Matrix {
+	return Matrix{A: 1, D: 1, E: x, F: y}
+}
+
+func Scale(x, y float64) Matrix {
+	return Matrix{A: x, D: y}
+}
+
+// Rotate creates a rotation matrix, angle in degrees, clockwise in the y-down coordinate system of SVG.
+func Rotate(angle float64) Matrix {
+	rad := angle * math.Pi / 180
+	cos := math.Cos(rad)
+	sin := math.Sin(rad)
+	return Matrix{A: cos, B: sin, C: -sin, D: cos}
+}
+
+func SkewX(angle float64) Matrix {
+	return Matrix{A: 1, C: math.Tan(angle * math.Pi / 180), D: 1}
+}
+
+func SkewY(angle float64) Matrix {
+	return Matrix{A: 1, B: math.Tan(angle * math.Pi / 180), D: 1}
+}
+
+// Multiply returns m x o, i.e. the transform that first applies o, then m.
+func (m Matrix) Multiply(o Matrix) Matrix {
+	return Matrix{
+		A: m.A*o.A + m.C*o.B,
+		B: m.B*o.A + m.D*o.B,
+		C: m.A*o.C + m.C*o.D,
+		D: m.B*o.C + m.D*o.D,
+		E: m.A*o.E + m.C*o.F + m.E,
+		F: m.B*o.E + m.D*o.F + m.F,
+	}
+}
+
+// Apply transforms the point
+func (m Matrix) Apply(p Point) Point {
+	return Point{
+		X: m.A*p.X + m.C*p.Y + m.E,
+		Y: m.B*p.X + m.D*p.Y + m.F,
+	}
+}
+
+func (m Matrix) IsIdentity() bool {
+	return m == Identity()
+}
+
+// ScaleFactor returns the factor by which lengths are scaled, for non-uniform scaling this is the geometric mean.
+func (m Matrix) ScaleFactor() float64 {
+	return math.Sqrt(math.Abs(m.A*m.D - m.B*m.C))
+}
+
+// IsUniform returns true if the transform scales equally in all directions and has no skew.
+func (m Matrix) IsUniform() bool {
+	const epsilon = 1e-6
+	sx, sy := m.Scales()
+	return math.Abs(sx-sy) < epsilon && !m.IsSkewed()
+}
+
+// Rotation returns the rotation of the transform in degrees, clockwise in the y-down coordinate system of SVG.
+// A mirroring transform can't be expressed as a rotation so it has none.
+func (m Matrix) Rotation() float64 {
+	if m.IsMirrored() {
+		return 0
+	}
+	return math.Atan2(m.B, m.A) * 180 / math.Pi
+}
+
+// IsMirrored returns true if the transform flips the orientation, such as scale(-1,1) does.
+func (m Matrix) IsMirrored() bool {
+	return m.A*m.D-m.B*m.C < 0
+}
+
+// Scales returns the scaling along the x and y axis of the transform.
+func (m Matrix) Scales() (x, y float64) {
+	x = math.Hypot(m.A, m.B)
+	y = math.Hypot(m.C, m.D)
+	return
+}
+
+// IsSkewed returns true if the transform doesn't keep right angles.
+func (m Matrix) IsSkewed() bool {
+	const epsilon = 1e-6
+	return math.Abs(m.A*m.C+m.B*m.D) > epsilon
+}
+
+// IsRotated returns true if the transform has a rotational component.
+func (m Matrix) IsRotated() bool {
+	const epsilon = 1e-6
+	return math.Abs(m.Rotation()) > epsilon
+}
+
+var transformExp = regexp.MustCompile(`\s*([a-zA-Z]+)\s*\(([^)]*)\)\s*,?`)
+
+// ParseTransform parses the value of a transform attribute
+func ParseTransform(transform string) (m Matrix, err error) {
+	m = Identity()
+	rest := strings.TrimSpace(transform)
+
+	for len(rest) > 0 {
+		match := transformExp.FindStringSubmatchIndex(rest)
+		if match == nil || match[0] != 0 {
+			err = fmt.Errorf("invalid transform '%s'", transform)
+			return
+		}
+
+		name := rest[match[2]:match[3]]
+		var args []float64
+		if args, err = ParseNumbers(rest[match[4]:match[5]]); err != nil {
+			return
+		}
+
+		var t Matrix
+		if t, err = createTransform(name, args); err != nil {
+			err = fmt.Errorf("%v in transform '%s'", err, transform)
+			return
+		}
+
+		m = m.Multiply(t)
+		rest = rest[match[1]:]
+	}
+
+	return
+}
+
+func createTransform(name string, args []float64) (m Matrix, err error) {
+	argCountError := func() error {
+		return fmt.Errorf("invalid number of arguments to %s: %d", name, len(args))
+	}
+
+	switch name {
+	case "matrix":
+		if len(args) != 6 {
+			err = argCountError()
+			return
+		}
+		m = Matrix{A: args[0], B: args[1], C: args[2], D: args[3], E: args[4], F: args[5]}
+	case "translate":
+		if len(args) == 1 {
+			m = Translate(args[0], 0)
+		} else if len(args) == 2 {
+			m = Translate(args[0], args[1])
+		} else {
+			err = argCountError()
+		}
+	case "scale":
+		if len(args) == 1 {
+			m = Scale(args[0], args[0])
+		} else if len(args) == 2 {
+			m = Scale(args[0], args[1])
+		} else {
+			err = argCountError()
+		}
+	case "rotate":
+		if len(args) == 1 {
+			m = Rotate(args[0])
+		} else if len(args) == 3 {
+			// Rotation around a point
+			m = Translate(args[1], args[2]).Multiply(Rotate(args[0])).Multiply(Translate(-args[1], -args[2]))
+		} else {
+			err = argCountError()
+		}
+	case "skewX":
+		if len(args) != 1 {
+			err = argCountError()
+			return
+		}
+		m = SkewX(args[0])
+	case "skewY":
+		if len(args) != 1 {
+			err = argCountError()
+			return
+		}
+		m = SkewY(args[0])
+	default:
+		err = fmt.Errorf("unknown transform function '%s'", name)
+	}
+
+	return
+}
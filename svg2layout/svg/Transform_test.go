@@ -0,0 +1,108 @@
+package svg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func assertPoint(t *testing.T, expected, actual Point) {
+	assert.InDelta(t, expected.X, actual.X, 1e-9)
+	assert.InDelta(t, expected.Y, actual.Y, 1e-9)
+}
+
+func TestParseTransform(t *testing.T) {
+	m, err := ParseTransform("")
+	assert.NoError(t, err)
+	assert.True(t, m.IsIdentity())
+
+	m, err = ParseTransform("translate(10,20)")
+	assert.NoError(t, err)
+	assertPoint(t, Point{11, 22}, m.Apply(Point{1, 2}))
+
+	m, err = ParseTransform("translate(10)")
+	assert.NoError(t, err)
+	assertPoint(t, Point{11, 2}, m.Apply(Point{1, 2}))
+
+	// Applied right to left
+	m, err = ParseTransform("translate(10, 20) scale(2)")
+	assert.NoError(t, err)
+	assertPoint(t, Point{12, 24}, m.Apply(Point{1, 2}))
+	assert.Equal(t, 2.0, m.ScaleFactor())
+
+	m, err = ParseTransform("scale(2,3)")
+	assert.NoError(t, err)
+	assertPoint(t, Point{2, 6}, m.Apply(Point{1, 2}))
+	assert.False(t, m.IsUniform())
+
+	m, err = ParseTransform("rotate(90)")
+	assert.NoError(t, err)
+	assertPoint(t, Point{0, 1}, m.Apply(Point{1, 0}))
+	assert.True(t, m.IsUniform())
+
+	m, err = ParseTransform("rotate(90 10 10)")
+	assert.NoError(t, err)
+	assertPoint(t, Point{10, 10}, m.Apply(Point{10, 10}))
+	assertPoint(t, Point{10, 11}, m.Apply(Point{11, 10}))
+
+	m, err = ParseTransform("matrix(1,0,0,1,5,6)")
+	assert.NoError(t, err)
+	assertPoint(t, Point{6, 8}, m.Apply(Point{1, 2}))
+
+	m, err = ParseTransform("skewX(45)")
+	assert.NoError(t, err)
+	assertPoint(t, Point{3, 2}, m.Apply(Point{1, 2}))
+
+	m, err = ParseTransform("skewY(45)")
+	assert.NoError(t, err)
+	assertPoint(t, Point{1, 3}, m.Apply(Point{1, 2}))
+	assert.InDelta(t, 1, m.ScaleFactor(), 1e-9)
+	assert.False(t, m.IsUniform())
+
+	_, err = ParseTransform("rotate(1,2)")
+	assert.Error(t, err)
+	_, err = ParseTransform("foo(1)")
+	assert.Error(t, err)
+	_, err = ParseTransform("translate(1,2) garbage")
+	assert.Error(t, err)
+}
+
+func TestMatrixDecomposition(t *testing.T) {
+	m, err := ParseTransform("translate(5,5) rotate(30) scale(2,3)")
+	assert.NoError(t, err)
+	assert.InDelta(t, 30, m.Rotation(), 1e-9)
+	sx, sy := m.Scales()
+	assert.InDelta(t, 2, sx, 1e-9)
+	assert.InDelta(t, 3, sy, 1e-9)
+	assert.True(t, m.IsRotated())
+	assert.False(t, m.IsSkewed())
+
+	m, err = ParseTransform("skewX(10)")
+	assert.NoError(t, err)
+	assert.False(t, m.IsRotated())
+	assert.True(t, m.IsSkewed())
+
+	// Mirroring is not a rotation by 180 degrees
+	m, err = ParseTransform("scale(-1,1)")
+	assert.NoError(t, err)
+	assert.True(t, m.IsMirrored())
+	assert.False(t, m.IsRotated())
+	assert.EqualValues(t, 0, m.Rotation())
+
+	m, err = ParseTransform("rotate(180)")
+	assert.NoError(t, err)
+	assert.False(t, m.IsMirrored())
+	assert.True(t, m.IsRotated())
+}
+
+func TestMatrixMultiply(t *testing.T) {
+	a := Translate(10, 0)
+	b := Rotate(180)
+	// Rotate first, then translate
+	assertPoint(t, Point{9, 0}, a.Multiply(b).Apply(Point{1, 0}))
+	// Translate first, then rotate
+	assertPoint(t, Point{-11, 0}, b.Multiply(a).Apply(Point{1, 0}))
+	assert.InDelta(t, 1, b.ScaleFactor(), 1e-9)
+	assert.InDelta(t, math.Sqrt(6), Scale(2, 3).ScaleFactor(), 1e-9)
+}